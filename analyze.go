@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"sort"
+)
+
+// AnalyzeOptions configures Vulnerability
+type AnalyzeOptions struct {
+	// Fast takes the lower bound of a min-cost flow as the turns after a closure instead of planning again
+	Fast bool
+	// Workers is the number of closures analyzed at the same time
+	Workers int
+}
+
+// Impact is what closing one station or track does to a plan
+type Impact struct {
+	Kind        string // "station" or "link"
+	Element     string // the station name, or the two station names of the link joined by '-'
+	Turns       int    // turns needed after the closure, 0 when it disconnects the ends
+	Increase    int    // extra turns compared to the open network, for bound compared to its lower bound
+	Disconnects bool   // no route is left between the ends
+	Method      string // how the turns were found: unused, cut, bound or replan
+}
+
+// Vulnerability closes every station and track in turn and ranks them by how many turns the trains
+// from source to destination lose, worst first. Closures are cheap to judge where possible: the turns
+// cannot grow when the plan does not use the closed element, and when the closure leaves no route
+// between source and destination the min-cut is gone. The rest are planned again, or with Fast set
+// estimated by the min-cost flow lower bound
+func (network *RailNetwork) Vulnerability(ctx context.Context, source, destination string, trainCount int, options AnalyzeOptions) (int, []Impact, error) {
+	result, err := network.PlanContext(ctx, source, destination, trainCount, PlanOptions{})
+	if err != nil {
+		return 0, nil, err
+	}
+	plan := result.Plan
+	usedStations := make(map[string]bool)
+	usedLinks := make(map[[2]string]bool)
+	for i, route := range plan.routes {
+		if plan.trainDistribution[i] == 0 {
+			continue
+		}
+		for j, station := range route {
+			usedStations[station] = true
+			if j > 0 {
+				usedLinks[linkKey(route[j-1], station)] = true
+			}
+		}
+	}
+
+	// the bound of a closure is held against the bound of the open network, the plan can take longer than
+	// its bound and the difference would make closures look like they save turns
+	baseline := plan.totalTurns
+	if options.Fast {
+		if baseline, err = network.TurnLowerBound(source, destination, trainCount); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	var impacts []Impact
+	var closed [][]string // the closed station, or the two stations of the closed link
+	for _, name := range network.sortedStationNames() {
+		if name == source || name == destination {
+			continue
+		}
+		impact := Impact{Kind: "station", Element: formatName(name)}
+		if !usedStations[name] {
+			impact.Turns, impact.Method = plan.totalTurns, "unused"
+		}
+		impacts = append(impacts, impact)
+		closed = append(closed, []string{name})
+	}
+	for _, link := range network.sortedLinks() {
+		impact := Impact{Kind: "link", Element: formatRoute(link[:])}
+		if !usedLinks[link] {
+			impact.Turns, impact.Method = plan.totalTurns, "unused"
+		}
+		impacts = append(impacts, impact)
+		closed = append(closed, []string{link[0], link[1]})
+	}
+
+	errs := make([]error, len(impacts))
+	parallelFor(options.Workers, len(impacts), func(i int) {
+		if impacts[i].Method != "" || ctx.Err() != nil {
+			return
+		}
+		// the copies are made one at a time, a copy per element up front would not fit large maps
+		var reduced *RailNetwork
+		if len(closed[i]) == 1 {
+			reduced = network.Without(closed[i][0])
+		} else {
+			reduced = network.WithoutLink(closed[i][0], closed[i][1])
+		}
+		bound, err := reduced.TurnLowerBound(source, destination, trainCount)
+		switch {
+		case err != nil:
+			impacts[i].Disconnects, impacts[i].Method = true, "cut"
+		case options.Fast:
+			impacts[i].Turns, impacts[i].Method = bound, "bound"
+		default:
+			result, err := reduced.PlanContext(ctx, source, destination, trainCount, PlanOptions{})
+			impacts[i].Turns, impacts[i].Method, errs[i] = result.Plan.totalTurns, "replan", err
+		}
+	})
+	if err := ctx.Err(); err != nil {
+		return 0, nil, err
+	}
+	for _, err := range errs {
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	for i := range impacts {
+		switch {
+		case impacts[i].Disconnects:
+		case impacts[i].Method == "bound":
+			impacts[i].Increase = impacts[i].Turns - baseline
+		default:
+			impacts[i].Increase = impacts[i].Turns - plan.totalTurns
+		}
+	}
+	sort.SliceStable(impacts, func(a, b int) bool {
+		if impacts[a].Disconnects != impacts[b].Disconnects {
+			return impacts[a].Disconnects
+		}
+		return impacts[a].Increase > impacts[b].Increase
+	})
+	return plan.totalTurns, impacts, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// TestVulnerability tests the ranking on network3.map, where losing either route costs a turn
+func TestVulnerability(t *testing.T) {
+	network, err := LoadNetworkMap("network3.map")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	turns, impacts, err := network.Vulnerability(context.Background(), "waterloo", "st_pancras", 2, AnalyzeOptions{Workers: 2})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if turns != 2 {
+		t.Fatalf("Expected 2 turns, got %d", turns)
+	}
+	expected := []Impact{
+		{"station", "euston", 3, 1, false, "replan"},
+		{"station", "victoria", 3, 1, false, "replan"},
+		{"link", "euston-st_pancras", 3, 1, false, "replan"},
+		{"link", "euston-waterloo", 3, 1, false, "replan"},
+		{"link", "st_pancras-victoria", 3, 1, false, "replan"},
+		{"link", "victoria-waterloo", 3, 1, false, "replan"},
+	}
+	if !reflect.DeepEqual(impacts[:len(expected)], expected) {
+		t.Fatalf("Expected\n%v\ngot\n%v", expected, impacts)
+	}
+	for _, impact := range impacts[len(expected):] {
+		if impact.Increase != 0 || impact.Method != "unused" {
+			t.Fatalf("Expected closures off the plan to cost nothing, got %+v", impact)
+		}
+	}
+}
+
+// TestVulnerability_Cut tests that closures leaving no route are found by the min-cut and ranked first
+func TestVulnerability_Cut(t *testing.T) {
+	network := NewRailNetwork()
+	for _, name := range []string{"a", "b", "c", "d", "z"} {
+		network.AddLocation(name)
+	}
+	// every route passes b, c-d is a detour next to the shorter b-z
+	for _, link := range [][2]string{{"a", "b"}, {"b", "z"}, {"b", "c"}, {"c", "d"}, {"d", "z"}} {
+		network.AddLink(link[0], link[1])
+	}
+
+	_, impacts, err := network.Vulnerability(context.Background(), "a", "z", 3, AnalyzeOptions{Fast: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []Impact{
+		{"station", "b", 0, 0, true, "cut"},
+		{"link", "a-b", 0, 0, true, "cut"},
+		{"link", "b-z", 6, 2, false, "bound"},
+	}
+	if !reflect.DeepEqual(impacts[:3], expected) {
+		t.Fatalf("Expected\n%v\ngot\n%v", expected, impacts)
+	}
+}
+
+// TestVulnerability_FastMatchesReplan tests that the lower bound estimates agree with planning again on the shipped maps
+func TestVulnerability_FastMatchesReplan(t *testing.T) {
+	for _, query := range shippedQueries[:8] {
+		network, err := LoadNetworkMap(query.file)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		_, exact, err := network.Vulnerability(context.Background(), query.source, query.end, query.trains, AnalyzeOptions{Workers: 4})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", query.file, err)
+		}
+		_, fast, err := network.Vulnerability(context.Background(), query.source, query.end, query.trains, AnalyzeOptions{Fast: true, Workers: 4})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", query.file, err)
+		}
+		for i := range exact {
+			if exact[i].Element != fast[i].Element || exact[i].Turns != fast[i].Turns || exact[i].Disconnects != fast[i].Disconnects {
+				t.Fatalf("%s: replanning gave %+v, the lower bound %+v", query.file, exact[i], fast[i])
+			}
+		}
+	}
+}
+
+// TestVulnerability_FastNeverSaves tests that no closure looks like it saves turns on a network whose
+// lower bound of 6 turns is below the 7 turns of its plan
+func TestVulnerability_FastNeverSaves(t *testing.T) {
+	network := NewRailNetwork()
+	for i := 0; i < 9; i++ {
+		network.AddLocation(fmt.Sprintf("r%d", i))
+	}
+	links := [][2]string{{"r0", "r1"}, {"r0", "r2"}, {"r0", "r3"}, {"r0", "r4"}, {"r1", "r2"}, {"r1", "r3"},
+		{"r1", "r5"}, {"r1", "r7"}, {"r1", "r8"}, {"r3", "r4"}, {"r3", "r5"}, {"r3", "r7"}, {"r4", "r5"},
+		{"r5", "r6"}, {"r5", "r8"}, {"r6", "r7"}, {"r6", "r8"}}
+	for _, link := range links {
+		network.AddLink(link[0], link[1])
+	}
+
+	turns, impacts, err := network.Vulnerability(context.Background(), "r0", "r8", 10, AnalyzeOptions{Fast: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if turns != 7 {
+		t.Fatalf("Expected a plan of 7 turns, got %d", turns)
+	}
+	for _, impact := range impacts {
+		if impact.Increase < 0 {
+			t.Fatalf("Expected no closure to save turns, got %+v", impact)
+		}
+	}
+}
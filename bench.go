@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// benchCase is a generated map the planner is measured on, trains travel between its first and last station
+type benchCase struct {
+	name    string
+	options GenerateOptions
+}
+
+// benchCases grow in size and density until the number of routes starts to explode,
+// they are shared by the Benchmark functions and the bench command
+var benchCases = []benchCase{
+	{"grid16", GenerateOptions{Stations: 16, Degree: 2.5, Topology: "grid", Seed: 1}},
+	{"grid36", GenerateOptions{Stations: 36, Degree: 2.5, Topology: "grid", Seed: 1}},
+	{"grid36dense", GenerateOptions{Stations: 36, Degree: 2.7, Topology: "grid", Seed: 1}},
+	{"grid64", GenerateOptions{Stations: 64, Degree: 2.4, Topology: "grid", Seed: 1}},
+	{"planar100", GenerateOptions{Stations: 100, Degree: 2.3, Topology: "planar", Seed: 1}},
+	{"scalefree20", GenerateOptions{Stations: 20, Degree: 4, Topology: "scale-free", Seed: 1}},
+}
+
+// endpoints returns the stations a benchmark plans between
+func (bench benchCase) endpoints() (string, string) {
+	width := len(fmt.Sprint(bench.options.Stations - 1))
+	return fmt.Sprintf("s%0*d", width, 0), fmt.Sprintf("s%0*d", width, bench.options.Stations-1)
+}
+
+// writeMap generates the network of the case and writes it into dir, returning the file name
+func (bench benchCase) writeMap(dir string) (string, error) {
+	network, err := GenerateNetwork(bench.options)
+	if err != nil {
+		return "", err
+	}
+	filename := filepath.Join(dir, bench.name+".map")
+	file, err := os.Create(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if err := network.WriteNetworkMap(file); err != nil {
+		return "", err
+	}
+	return filename, file.Close()
+}
+
+// stageReport is the cost of one planner stage
+type stageReport struct {
+	stage    string
+	duration time.Duration
+	allocs   uint64
+	bytes    uint64
+	stopped  bool // the stage ran out of time and worked on partial results
+}
+
+// measure runs work once and reports its wall time and heap allocations
+func measure(stage string, work func() bool) stageReport {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	stopped := work()
+	duration := time.Since(start)
+	runtime.ReadMemStats(&after)
+	return stageReport{
+		stage:    stage,
+		duration: duration,
+		allocs:   after.Mallocs - before.Mallocs,
+		bytes:    after.TotalAlloc - before.TotalAlloc,
+		stopped:  stopped,
+	}
+}
+
+// runBenchCase measures every planner stage on the map of the case, stopping route search and
+// validation after timeout so a blowup shows up as a stopped stage instead of a hang
+func runBenchCase(bench benchCase, dir string, trainCount, workers int, timeout time.Duration) ([]stageReport, error) {
+	filename, err := bench.writeMap(dir)
+	if err != nil {
+		return nil, err
+	}
+	source, destination := bench.endpoints()
+
+	var reports []stageReport
+	var network *RailNetwork
+	reports = append(reports, measure("load", func() bool {
+		network, err = LoadNetworkMapWithOptions(filename, LoadOptions{})
+		return false
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	var routes [][]string
+	reports = append(reports, measure("explore", func() bool {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		var complete bool
+		routes, complete, err = network.ExplorePathsContext(ctx, source, destination, ExploreOptions{Workers: workers})
+		return !complete
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	var validRoutes [][][]string
+	reports = append(reports, measure("validate", func() bool {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		var complete bool
+		validRoutes, complete = ValidateRoutesContext(ctx, routes, workers)
+		return !complete
+	}))
+
+	var plan routePlan
+	reports = append(reports, measure("allocate", func() bool {
+		plan = AllocateTrains(trainCount, SelectOptimalCombos(validRoutes))
+		return false
+	}))
+	reports = append(reports, measure("display", func() bool {
+		WriteSchedule(io.Discard, plan, trainCount)
+		return false
+	}))
+	return reports, nil
+}
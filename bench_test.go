@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestRunBenchCase tests that every planner stage is measured and finishes on a small map
+func TestRunBenchCase(t *testing.T) {
+	reports, err := runBenchCase(benchCases[0], t.TempDir(), 5, 1, time.Minute)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{"load", "explore", "validate", "allocate", "display"}
+	if len(reports) != len(expected) {
+		t.Fatalf("Expected %d stages, got %v", len(expected), reports)
+	}
+	for i, report := range reports {
+		if report.stage != expected[i] || report.stopped || report.allocs == 0 {
+			t.Fatalf("Expected a finished %s stage that allocated, got %+v", expected[i], report)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"math"
+)
+
+// TurnLowerBound returns a number of turns no schedule for trainCount trains from source to destination
+// can beat. Routes whose intermediate stations differ are found by min-cost max-flow: with k routes
+// that have s intermediate stations in total the last of n trains cannot arrive before turn (n+s)/k,
+// rounded up. The bound is the smallest of these over every possible number of routes
+func (network *RailNetwork) TurnLowerBound(source, destination string, trainCount int) (int, error) {
+	if trainCount <= 0 {
+		return 0, errors.New("number of trains must be a valid positive integer")
+	}
+	costs, err := network.DisjointRouteCosts(source, destination, trainCount)
+	if err != nil {
+		return 0, err
+	}
+	if len(costs) == 0 {
+		return 0, network.checkConnected(source, destination)
+	}
+	bound := math.MaxInt
+	for i, intermediates := range costs {
+		routes := i + 1
+		bound = min(bound, (trainCount+intermediates+routes-1)/routes)
+	}
+	return bound, nil
+}
+
+// DisjointRouteCosts returns for k = 1, 2, ... the smallest total number of intermediate stations on
+// k routes from source to destination that share no intermediate station. The slice stops at limit
+// routes or at the largest number of such routes, whichever comes first. A limit of 0 means no limit
+func (network *RailNetwork) DisjointRouteCosts(source, destination string, limit int) ([]int, error) {
+	if err := network.checkEndpoints(source, destination); err != nil {
+		return nil, err
+	}
+
+	graph := network.Compact()
+	flow := newStationFlow(graph, graph.ids[source], graph.ids[destination])
+	var costs []int
+	total := 0
+	for limit <= 0 || len(costs) < limit {
+		cost, found := flow.augment()
+		if !found {
+			break
+		}
+		total += cost
+		costs = append(costs, total)
+	}
+	return costs, nil
+}
+
+// stationFlow is the residual network of a min-cost flow where every station except the two ends
+// is split into an in and an out node joined by an arc of capacity 1 and cost 1, so a unit of flow
+// is a route and its cost is the number of intermediate stations on it
+type stationFlow struct {
+	arcs         []flowArc
+	first        []int32 // first arc leaving each node, -1 if none
+	source, sink int32
+}
+
+// flowArc is an arc of the residual network, arcs are stored in pairs so arc i^1 is the reverse of arc i
+type flowArc struct {
+	to, next int32
+	capacity int32
+	cost     int32
+}
+
+func newStationFlow(graph *compactGraph, source, destination int32) *stationFlow {
+	nodes := 2 * graph.stationCount()
+	flow := &stationFlow{first: make([]int32, nodes), source: 2*source + 1, sink: 2 * destination}
+	for i := range flow.first {
+		flow.first[i] = -1
+	}
+	for id := int32(0); id < int32(graph.stationCount()); id++ {
+		if id != source && id != destination {
+			flow.addArc(2*id, 2*id+1, 1, 1)
+		}
+		// a track carries one route, a direct track between the ends included
+		for _, neighbor := range graph.neighbors(id) {
+			flow.addArc(2*id+1, 2*neighbor, 1, 0)
+		}
+	}
+	return flow
+}
+
+// addArc adds an arc from node a to node b and its reverse residual arc
+func (flow *stationFlow) addArc(a, b, capacity, cost int32) {
+	flow.arcs = append(flow.arcs, flowArc{to: b, next: flow.first[a], capacity: capacity, cost: cost})
+	flow.first[a] = int32(len(flow.arcs) - 1)
+	flow.arcs = append(flow.arcs, flowArc{to: a, next: flow.first[b], capacity: 0, cost: -cost})
+	flow.first[b] = int32(len(flow.arcs) - 1)
+}
+
+// augment sends one more unit of flow along the cheapest residual path found by Bellman-Ford
+// with a queue and returns the cost of that path, false when no more flow fits
+func (flow *stationFlow) augment() (int, bool) {
+	nodes := len(flow.first)
+	distances := make([]int32, nodes)
+	through := make([]int32, nodes) // arc used to reach each node
+	queued := make([]bool, nodes)
+	for i := range distances {
+		distances[i] = math.MaxInt32
+		through[i] = -1
+	}
+	distances[flow.source] = 0
+	queue := []int32{flow.source}
+	queued[flow.source] = true
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		queued[node] = false
+		for i := flow.first[node]; i >= 0; i = flow.arcs[i].next {
+			arc := flow.arcs[i]
+			if arc.capacity > 0 && distances[node]+arc.cost < distances[arc.to] {
+				distances[arc.to] = distances[node] + arc.cost
+				through[arc.to] = i
+				if !queued[arc.to] {
+					queued[arc.to] = true
+					queue = append(queue, arc.to)
+				}
+			}
+		}
+	}
+	if distances[flow.sink] == math.MaxInt32 {
+		return 0, false
+	}
+	for node := flow.sink; node != flow.source; node = flow.arcs[through[node]^1].to {
+		flow.arcs[through[node]].capacity--
+		flow.arcs[through[node]^1].capacity++
+	}
+	return int(distances[flow.sink]), true
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestTurnLowerBound_ShippedMaps tests that the planner reaches the lower bound on every shipped map
+func TestTurnLowerBound_ShippedMaps(t *testing.T) {
+	for _, query := range shippedQueries {
+		network, err := LoadNetworkMap(query.file)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		plan, err := network.Plan(query.source, query.end, query.trains)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", query.file, err)
+		}
+		bound, err := network.TurnLowerBound(query.source, query.end, query.trains)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", query.file, err)
+		}
+		if bound != plan.totalTurns {
+			t.Fatalf("%s: expected the lower bound to be %d turns, got %d", query.file, plan.totalTurns, bound)
+		}
+	}
+}
+
+// TestDisjointRouteCosts tests that the min-cost flow reroutes the shortest route to fit a second one
+func TestDisjointRouteCosts(t *testing.T) {
+	// the shortest route s-a-b-t leaves no room for a second one, s-a-d-t and s-c-b-t fit together
+	network := NewRailNetwork()
+	for _, name := range []string{"s", "a", "b", "c", "d", "t"} {
+		network.AddLocation(name)
+	}
+	for _, link := range [][2]string{{"s", "a"}, {"a", "b"}, {"b", "t"}, {"s", "c"}, {"c", "b"}, {"a", "d"}, {"d", "t"}} {
+		network.AddLink(link[0], link[1])
+	}
+
+	costs, err := network.DisjointRouteCosts("s", "t", 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(costs, []int{2, 4}) {
+		t.Fatalf("Expected costs [2 4], got %v", costs)
+	}
+
+	bound, err := network.TurnLowerBound("s", "t", 5)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// one route needs 2+5 turns, two routes need (5+4)/2 rounded up
+	if bound != 5 {
+		t.Fatalf("Expected a lower bound of 5 turns, got %d", bound)
+	}
+}
+
+// TestTurnLowerBound_Errors tests the errors for invalid queries
+func TestTurnLowerBound_Errors(t *testing.T) {
+	network := gridNetwork(2, 2)
+	network.AddLocation("island")
+
+	tests := []struct {
+		source, destination string
+		trains              int
+		expected            string
+	}{
+		{"0_0", "1_1", 0, "number of trains must be a valid positive integer"},
+		{"0_0", "0_0", 1, "source and destination stations are the same"},
+		{"missing", "1_1", 1, "source station missing does not exist"},
+		{"0_0", "missing", 1, "destination station missing does not exist"},
+		{"0_0", "island", 1, "no routes found from start to end"},
+	}
+	for _, test := range tests {
+		_, err := network.TurnLowerBound(test.source, test.destination, test.trains)
+		if err == nil || err.Error() != test.expected {
+			t.Fatalf("Expected '%s' error, got: %v", test.expected, err)
+		}
+	}
+}
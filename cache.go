@@ -0,0 +1,239 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// PlanCache keeps planning results so the same query on the same network is answered without
+// planning again. Entries are keyed by the content hash of the network and the query, so a changed
+// map never finds the entries of its previous version. With a directory every entry is also written
+// there as JSON and read back by later runs
+type PlanCache struct {
+	dir   string
+	limit int // most entries kept in memory, the least recently used are dropped first
+
+	mutex   sync.Mutex
+	entries map[string]*list.Element // key -> element of order holding a *cacheEntry
+	order   *list.List               // most recently used first
+}
+
+type cacheEntry struct {
+	key         string
+	networkHash string
+	result      PlanResult
+}
+
+// cachedResult is the JSON form of a PlanResult in the cache directory
+type cachedResult struct {
+	Plan       cachedPlan   `json:"plan"`
+	LowerBound int          `json:"lowerBound"`
+	Candidates []cachedPlan `json:"candidates,omitempty"`
+}
+
+type cachedPlan struct {
+	Routes  [][]string `json:"routes"`
+	Lengths []int      `json:"lengths"`
+	Trains  []int      `json:"trains"`
+	Turns   int        `json:"turns"`
+}
+
+// NewPlanCache returns a cache holding up to limit results in memory, and in dir as well unless it is empty
+func NewPlanCache(dir string, limit int) (*PlanCache, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &PlanCache{dir: dir, limit: limit, entries: make(map[string]*list.Element), order: list.New()}, nil
+}
+
+// Plan answers the query from the cache or plans it with PlanContext and keeps the result, reporting
+// whether the cache had it. Results of stopped searches depend on timing and are not kept, and a result
+// that cannot be written to the directory is only kept in memory. A nil cache always plans
+func (cache *PlanCache) Plan(ctx context.Context, network *RailNetwork, source, destination string, trainCount int, options PlanOptions) (PlanResult, bool, error) {
+	if cache == nil {
+		result, err := network.PlanContext(ctx, source, destination, trainCount, options)
+		return result, false, err
+	}
+	networkHash := network.ContentHash()
+	key := planKey(networkHash, source, destination, trainCount, options)
+	if result, found := cache.get(key); found {
+		return result, true, nil
+	}
+
+	result, err := network.PlanContext(ctx, source, destination, trainCount, options)
+	if err != nil || result.Suboptimal {
+		return result, false, err
+	}
+	cache.put(&cacheEntry{key: key, networkHash: networkHash, result: result})
+	cache.write(key, result)
+	return result, false, nil
+}
+
+// Forget drops every result planned on the network with the given content hash
+func (cache *PlanCache) Forget(networkHash string) error {
+	if cache == nil {
+		return nil
+	}
+	cache.mutex.Lock()
+	for key, element := range cache.entries {
+		if element.Value.(*cacheEntry).networkHash == networkHash {
+			cache.order.Remove(element)
+			delete(cache.entries, key)
+		}
+	}
+	cache.mutex.Unlock()
+
+	if cache.dir == "" {
+		return nil
+	}
+	files, err := filepath.Glob(filepath.Join(cache.dir, networkHash+"-*.json"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Len returns the number of results held in memory
+func (cache *PlanCache) Len() int {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	return cache.order.Len()
+}
+
+// get looks the key up in memory and then in the directory
+func (cache *PlanCache) get(key string) (PlanResult, bool) {
+	cache.mutex.Lock()
+	if element, found := cache.entries[key]; found {
+		cache.order.MoveToFront(element)
+		cache.mutex.Unlock()
+		return element.Value.(*cacheEntry).result, true
+	}
+	cache.mutex.Unlock()
+
+	// a file that cannot be read is planned again and overwritten
+	result, err := cache.read(key)
+	if err != nil {
+		return PlanResult{}, false
+	}
+	networkHash, _, _ := strings.Cut(key, "-")
+	cache.put(&cacheEntry{key: key, networkHash: networkHash, result: result})
+	return result, true
+}
+
+func (cache *PlanCache) put(entry *cacheEntry) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if element, found := cache.entries[entry.key]; found {
+		element.Value = entry
+		cache.order.MoveToFront(element)
+		return
+	}
+	cache.entries[entry.key] = cache.order.PushFront(entry)
+	for cache.limit > 0 && cache.order.Len() > cache.limit {
+		oldest := cache.order.Back()
+		cache.order.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (cache *PlanCache) path(key string) string {
+	return filepath.Join(cache.dir, key+".json")
+}
+
+func (cache *PlanCache) read(key string) (PlanResult, error) {
+	if cache.dir == "" {
+		return PlanResult{}, os.ErrNotExist
+	}
+	data, err := os.ReadFile(cache.path(key))
+	if err != nil {
+		return PlanResult{}, err
+	}
+	var cached cachedResult
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return PlanResult{}, err
+	}
+	result := PlanResult{Plan: cached.Plan.routePlan(), LowerBound: cached.LowerBound}
+	for _, candidate := range cached.Candidates {
+		result.Candidates = append(result.Candidates, candidate.routePlan())
+	}
+	return result, nil
+}
+
+// write stores the result in the directory, through a temporary file so readers never see half of it
+func (cache *PlanCache) write(key string, result PlanResult) error {
+	if cache.dir == "" {
+		return nil
+	}
+	cached := cachedResult{Plan: newCachedPlan(result.Plan), LowerBound: result.LowerBound}
+	for _, candidate := range result.Candidates {
+		cached.Candidates = append(cached.Candidates, newCachedPlan(candidate))
+	}
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(cache.dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	return os.Rename(file.Name(), cache.path(key))
+}
+
+func newCachedPlan(plan routePlan) cachedPlan {
+	return cachedPlan{Routes: plan.routes, Lengths: plan.lengths, Trains: plan.trainDistribution, Turns: plan.totalTurns}
+}
+
+func (plan cachedPlan) routePlan() routePlan {
+	return routePlan{routes: plan.Routes, lengths: plan.Lengths, trainDistribution: plan.Trains, totalTurns: plan.Turns}
+}
+
+// planKey is the network hash followed by a hash of everything in the query that changes the result.
+// The number of workers does not, the routes they find are merged in the same order
+func planKey(networkHash, source, destination string, trainCount int, options PlanOptions) string {
+	query := sha256.New()
+	fmt.Fprintf(query, "%q %q %d %d %d %t", source, destination, trainCount, options.MaxPathLength, options.MaxPaths, options.Explain)
+	return networkHash + "-" + hex.EncodeToString(query.Sum(nil)[:16])
+}
+
+// ContentHash identifies the stations and tracks of the network. Coordinates, attributes and comments
+// do not change plans and are left out, so two maps that differ only in those have the same hash
+func (network *RailNetwork) ContentHash() string {
+	graph := network.Compact()
+	graph.hashOnce.Do(func() {
+		hash := sha256.New()
+		var buf [binary.MaxVarintLen64]byte
+		for _, name := range graph.names {
+			hash.Write(buf[:binary.PutUvarint(buf[:], uint64(len(name)))])
+			hash.Write([]byte(name))
+		}
+		binary.Write(hash, binary.LittleEndian, graph.offsets)
+		binary.Write(hash, binary.LittleEndian, graph.adjacency)
+		graph.hash = hex.EncodeToString(hash.Sum(nil)[:16])
+	})
+	return graph.hash
+}
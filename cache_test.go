@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// planCached plans through the cache and fails the test on an error
+func planCached(t *testing.T, cache *PlanCache, network *RailNetwork, trainCount int, options PlanOptions) (PlanResult, bool) {
+	t.Helper()
+	result, cached, err := cache.Plan(context.Background(), network, "small", "large", trainCount, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result, cached
+}
+
+// TestPlanCache tests that a query is planned once and answered from memory afterwards
+func TestPlanCache(t *testing.T) {
+	network, err := LoadNetworkMap("network7.map")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, err := NewPlanCache("", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	planned, cached := planCached(t, cache, network, 9, PlanOptions{})
+	if cached {
+		t.Fatal("Expected the first query to be planned")
+	}
+	again, cached := planCached(t, cache, network, 9, PlanOptions{ExploreOptions: ExploreOptions{Workers: 4}})
+	if !cached || !reflect.DeepEqual(again, planned) {
+		t.Fatalf("Expected the same result from the cache, got %v %+v", cached, again)
+	}
+
+	// anything else that changes the result is planned again
+	if _, cached := planCached(t, cache, network, 9, PlanOptions{Explain: true}); cached {
+		t.Error("Expected an explained plan to be planned again")
+	}
+	if _, cached := planCached(t, cache, network, 8, PlanOptions{}); cached {
+		t.Error("Expected another number of trains to be planned again")
+	}
+	if _, cached := planCached(t, cache, network.Without("12"), 9, PlanOptions{}); cached {
+		t.Error("Expected a changed network to be planned again")
+	}
+	if cache.Len() != 4 {
+		t.Fatalf("Expected 4 results in the cache, got %d", cache.Len())
+	}
+}
+
+// TestPlanCache_Directory tests that results written to the directory are found by another cache
+func TestPlanCache_Directory(t *testing.T) {
+	network, err := LoadNetworkMap("network7.map")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(t.TempDir(), "plans")
+	first, err := NewPlanCache(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	planned, _ := planCached(t, first, network, 9, PlanOptions{Explain: true})
+
+	second, err := NewPlanCache(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, cached := planCached(t, second, network, 9, PlanOptions{Explain: true})
+	if !cached || !reflect.DeepEqual(loaded, planned) {
+		t.Fatalf("Expected the result written by the first cache, got %v %+v", cached, loaded)
+	}
+
+	// a damaged file is planned again and replaced
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("Expected one file in the cache directory, got %v", files)
+	}
+	if err := os.WriteFile(files[0], []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	third, _ := NewPlanCache(dir, 0)
+	if _, cached := planCached(t, third, network, 9, PlanOptions{Explain: true}); cached {
+		t.Fatal("Expected a damaged file to be planned again")
+	}
+	fourth, _ := NewPlanCache(dir, 0)
+	if _, cached := planCached(t, fourth, network, 9, PlanOptions{Explain: true}); !cached {
+		t.Fatal("Expected the damaged file to be replaced")
+	}
+
+	if err := fourth.Forget(network.ContentHash()); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(files) != 0 || fourth.Len() != 0 {
+		t.Fatalf("Expected Forget to remove every result, got %v and %d in memory", files, fourth.Len())
+	}
+}
+
+// TestPlanCache_Limit tests that the least recently used results are dropped first
+func TestPlanCache_Limit(t *testing.T) {
+	network, err := LoadNetworkMap("network7.map")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, _ := NewPlanCache("", 2)
+	planCached(t, cache, network, 1, PlanOptions{})
+	planCached(t, cache, network, 2, PlanOptions{})
+	planCached(t, cache, network, 1, PlanOptions{})
+	planCached(t, cache, network, 3, PlanOptions{})
+	if cache.Len() != 2 {
+		t.Fatalf("Expected 2 results, got %d", cache.Len())
+	}
+	if _, cached := planCached(t, cache, network, 1, PlanOptions{}); !cached {
+		t.Error("Expected the recently used result to be kept")
+	}
+	if _, cached := planCached(t, cache, network, 2, PlanOptions{}); cached {
+		t.Error("Expected the least recently used result to be dropped")
+	}
+}
+
+// TestPlanCache_Suboptimal tests that results of stopped searches are not kept
+func TestPlanCache_Suboptimal(t *testing.T) {
+	network, err := LoadNetworkMap("network7.map")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, _ := NewPlanCache("", 0)
+	result, _ := planCached(t, cache, network, 9, PlanOptions{ExploreOptions: ExploreOptions{MaxPaths: 3}})
+	if !result.Suboptimal {
+		t.Fatal("Expected the search to be stopped by the limit")
+	}
+	if cache.Len() != 0 {
+		t.Fatalf("Expected a stopped search not to be kept, got %d results", cache.Len())
+	}
+}
+
+// TestPlanCache_Nil tests that a nil cache plans every query
+func TestPlanCache_Nil(t *testing.T) {
+	network, err := LoadNetworkMap("network3.map")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cache *PlanCache
+	for i := 0; i < 2; i++ {
+		result, cached, err := cache.Plan(context.Background(), network, "waterloo", "st_pancras", 2, PlanOptions{})
+		if err != nil || cached || result.Plan.totalTurns == 0 {
+			t.Fatalf("Expected a plan, got %+v %v %v", result, cached, err)
+		}
+	}
+}
+
+// TestContentHash tests that only stations and tracks change the hash
+func TestContentHash(t *testing.T) {
+	original, err := LoadNetworkMap("network7.map")
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied := original.Without()
+	if copied.ContentHash() != original.ContentHash() {
+		t.Fatal("Expected a copy to have the same hash")
+	}
+	copied.SetCoordinates("small", 100, 100)
+	copied.SetAttribute("small", "zone", "2")
+	if copied.ContentHash() != original.ContentHash() {
+		t.Fatal("Expected coordinates and attributes to leave the hash unchanged")
+	}
+	if original.WithoutLink("small", "10").ContentHash() == original.ContentHash() {
+		t.Fatal("Expected a closed track to change the hash")
+	}
+	renamed := NewRailNetwork()
+	renamed.AddLocation("ab")
+	renamed.AddLocation("c")
+	split := NewRailNetwork()
+	split.AddLocation("a")
+	split.AddLocation("bc")
+	if renamed.ContentHash() == split.ContentHash() {
+		t.Fatal("Expected names to be told apart where they end")
+	}
+}
@@ -0,0 +1,636 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// commands maps subcommand names to their handlers, every handler gets the arguments after the name
+var commands = map[string]func(args []string) error{
+	"analyze":  runAnalyze,
+	"bench":    runBench,
+	"fmt":      runFmt,
+	"generate": runGenerate,
+	"lint":     runLint,
+	"paths":    runPaths,
+	"repl":     runRepl,
+	"replan":   runReplan,
+	"serve":    runServe,
+	"shortest": runShortest,
+	"stats":    runStats,
+}
+
+// runAnalyze ranks stations and links by how many turns their closure costs the trains between two stations
+func runAnalyze(args []string) error {
+	flags := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	fast := flags.Bool("fast", false, "estimate the turns after a closure with the min-cost flow lower bound instead of planning again")
+	all := flags.Bool("all", false, "also list closures that cost no turns")
+	workers := flags.Int("workers", runtime.NumCPU(), "closures analyzed at the same time")
+	timeout := flags.Duration("timeout", 0, "give up after this long, 0 for no limit")
+	maxStations := flags.Int("max-stations", DefaultLoadOptions.MaxStations, "largest accepted number of stations, 0 for no limit")
+	ignoreCase := flags.Bool("ignore-case", false, "accept station names that differ only in case")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 4 {
+		return fmt.Errorf("usage: go run . analyze [-fast] [-all] [-workers n] [-timeout d] [-ignore-case] <network map file> <start station> <end station> <number of trains>")
+	}
+	trainCount, err := strconv.Atoi(flags.Arg(3))
+	if err != nil || trainCount <= 0 {
+		return fmt.Errorf("number of trains must be a valid positive integer")
+	}
+
+	network, err := LoadNetworkMapWithOptions(flags.Arg(0), LoadOptions{MaxStations: *maxStations})
+	if err != nil {
+		return fmt.Errorf("error loading network map: %v", err)
+	}
+	source, destination := flags.Arg(1), flags.Arg(2)
+	if *ignoreCase {
+		if source, destination, err = network.ResolveEndpoints(source, destination); err != nil {
+			return err
+		}
+	}
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	turns, impacts, err := network.Vulnerability(ctx, source, destination, trainCount, AnalyzeOptions{Fast: *fast, Workers: *workers})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d trains from %s to %s take %d turns\n\n", trainCount, formatName(source), formatName(destination), turns)
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(out, "rank\tclosed\tturns\tincrease\tmethod")
+	rank := 0
+	for _, impact := range impacts {
+		if impact.Increase == 0 && !impact.Disconnects && !*all {
+			continue
+		}
+		rank++
+		if impact.Disconnects {
+			fmt.Fprintf(out, "%d\t%s %s\tno route\t-\t%s\n", rank, impact.Kind, impact.Element, impact.Method)
+		} else {
+			fmt.Fprintf(out, "%d\t%s %s\t%d\t+%d\t%s\n", rank, impact.Kind, impact.Element, impact.Turns, impact.Increase, impact.Method)
+		}
+	}
+	if rank == 0 {
+		fmt.Fprintln(out, "no single closure costs any turns")
+	}
+	return out.Flush()
+}
+
+// runBench measures time and allocations of every planner stage on generated maps of growing size
+func runBench(args []string) error {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	trains := flags.Int("trains", 10, "number of trains to plan for")
+	workers := flags.Int("workers", 1, "goroutines used by route search and combination building")
+	timeout := flags.Duration("timeout", 10*time.Second, "time after which route search and validation are stopped")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return fmt.Errorf("usage: go run . bench [-trains n] [-workers n] [-timeout d]")
+	}
+
+	dir, err := os.MkdirTemp("", "stations-bench")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(out, "map\tstations\tdegree\tstage\ttime\tallocs\tbytes\t")
+	for _, bench := range benchCases {
+		reports, err := runBenchCase(bench, dir, *trains, *workers, *timeout)
+		if err != nil {
+			return fmt.Errorf("%s: %v", bench.name, err)
+		}
+		for _, report := range reports {
+			duration := report.duration.Round(time.Microsecond).String()
+			if report.stopped {
+				duration = "stopped " + duration
+			}
+			fmt.Fprintf(out, "%s\t%d\t%g\t%s\t%s\t%d\t%d\t\n", bench.name, bench.options.Stations, bench.options.Degree,
+				report.stage, duration, report.allocs, report.bytes)
+		}
+	}
+	return out.Flush()
+}
+
+// runFmt rewrites map files into canonical layout, like gofmt does for Go code
+func runFmt(args []string) error {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	write := flags.Bool("w", false, "write result to the source file instead of standard output")
+	list := flags.Bool("l", false, "list files whose formatting differs from the canonical layout")
+	maxStations := flags.Int("max-stations", DefaultLoadOptions.MaxStations, "largest accepted number of stations, 0 for no limit")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("usage: go run . fmt [-w] [-l] <network map file>...")
+	}
+
+	for _, filename := range flags.Args() {
+		formatted, err := FormatMapFile(filename, LoadOptions{MaxStations: *maxStations})
+		if err != nil {
+			return fmt.Errorf("%s: %v", filename, err)
+		}
+		original, err := os.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		changed := !bytes.Equal(original, formatted)
+		if *list && changed {
+			fmt.Println(filename)
+		}
+		if *write {
+			if changed {
+				if err := os.WriteFile(filename, formatted, 0o644); err != nil {
+					return err
+				}
+			}
+		} else if !*list {
+			os.Stdout.Write(formatted)
+		}
+	}
+	return nil
+}
+
+// runGenerate writes a random network map, the same flags always give the same map
+func runGenerate(args []string) error {
+	flags := flag.NewFlagSet("generate", flag.ContinueOnError)
+	var options GenerateOptions
+	flags.IntVar(&options.Stations, "stations", 100, "number of stations")
+	flags.Float64Var(&options.Degree, "degree", 3, "average number of connections per station")
+	flags.StringVar(&options.Topology, "topology", "planar", "grid, planar, random-geometric or scale-free")
+	flags.Int64Var(&options.Seed, "seed", 1, "random seed")
+	output := flags.String("o", "", "file to write the map to instead of standard output")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return fmt.Errorf("usage: go run . generate [-stations n] [-degree d] [-topology t] [-seed s] [-o file]")
+	}
+
+	network, err := GenerateNetwork(options)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := network.WriteNetworkMap(&buf); err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err = os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return os.WriteFile(*output, buf.Bytes(), 0o644)
+}
+
+// runLint prints the warnings for every given map file and fails if there were any
+func runLint(args []string) error {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	maxStations := flags.Int("max-stations", DefaultLoadOptions.MaxStations, "largest accepted number of stations, 0 for no limit")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("usage: go run . lint <network map file>...")
+	}
+
+	count := 0
+	for _, filename := range flags.Args() {
+		warnings, err := LintNetworkMap(filename, LoadOptions{MaxStations: *maxStations})
+		if err != nil {
+			return fmt.Errorf("%s: %v", filename, err)
+		}
+		for _, warning := range warnings {
+			fmt.Printf("%s:%d: %s\n", warning.file, warning.line, warning.message)
+		}
+		count += len(warnings)
+	}
+	if count > 0 {
+		return fmt.Errorf("found %d lint warnings", count)
+	}
+	return nil
+}
+
+// runPaths prints the k shortest loopless routes between two stations
+func runPaths(args []string) error {
+	flags := flag.NewFlagSet("paths", flag.ContinueOnError)
+	k := flags.Int("k", 5, "number of routes to print")
+	weight := flags.String("weight", "hops", "route cost: hops counts tracks, distance adds up the straight line distances between stations")
+	maxStations := flags.Int("max-stations", DefaultLoadOptions.MaxStations, "largest accepted number of stations, 0 for no limit")
+	var exclude []string
+	flags.Func("exclude", "station no route may pass through (can be repeated)", func(name string) error {
+		exclude = append(exclude, name)
+		return nil
+	})
+	ignoreCase := flags.Bool("ignore-case", false, "accept station names that differ only in case")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 3 {
+		return fmt.Errorf("usage: go run . paths [-k n] [-weight hops|distance] [-exclude station]... [-ignore-case] <network map file> <start station> <end station>")
+	}
+
+	network, err := LoadNetworkMapWithOptions(flags.Arg(0), LoadOptions{MaxStations: *maxStations})
+	if err != nil {
+		return fmt.Errorf("error loading network map: %v", err)
+	}
+	source, destination := flags.Arg(1), flags.Arg(2)
+	if *ignoreCase {
+		if source, destination, err = network.ResolveEndpoints(source, destination); err != nil {
+			return err
+		}
+		for i, name := range exclude {
+			if exclude[i], err = network.ResolveStation("excluded", name); err != nil {
+				return err
+			}
+		}
+	}
+	options := PathOptions{Exclude: exclude}
+	switch *weight {
+	case "hops":
+	case "distance":
+		options.Weight = DistanceWeight(network)
+	default:
+		return fmt.Errorf("unknown weight %s, expected hops or distance", *weight)
+	}
+
+	routes, err := network.KShortestPaths(source, destination, *k, options)
+	if err != nil {
+		return err
+	}
+	for i, route := range routes {
+		if options.Weight == nil {
+			fmt.Printf("%d. %d tracks: %s\n", i+1, len(route.Stations)-1, formatRoute(route.Stations))
+		} else {
+			fmt.Printf("%d. %.2f: %s\n", i+1, route.Cost, formatRoute(route.Stations))
+		}
+	}
+	return nil
+}
+
+// runRepl loads a map once and answers commands typed one per line, see shellHelp
+func runRepl(args []string) error {
+	flags := flag.NewFlagSet("repl", flag.ContinueOnError)
+	maxStations := flags.Int("max-stations", DefaultLoadOptions.MaxStations, "largest accepted number of stations, 0 for no limit")
+	ignoreCase := flags.Bool("ignore-case", false, "accept station names that differ only in case")
+	timeout := flags.Duration("timeout", 0, "stop each plan after this long and print the best schedule found, 0 for no limit")
+	historyFile := flags.String("history", "", "file the typed lines are read from at the start and added to")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: go run . repl [-ignore-case] [-timeout d] [-history file] <network map file>")
+	}
+
+	network, err := LoadNetworkMapWithOptions(flags.Arg(0), LoadOptions{MaxStations: *maxStations})
+	if err != nil {
+		return fmt.Errorf("error loading network map: %v", err)
+	}
+	sh := newShell(network, os.Stdout)
+	sh.ignoreCase, sh.timeout = *ignoreCase, *timeout
+	if *historyFile != "" {
+		saved, err := os.ReadFile(*historyFile)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for _, line := range strings.Split(string(saved), "\n") {
+			if line != "" {
+				sh.history = append(sh.history, line)
+			}
+		}
+	}
+
+	in := bufio.NewReader(os.Stdin)
+	read := func() (string, error) {
+		line, err := in.ReadString('\n')
+		if err == io.EOF && line != "" {
+			err = nil
+		}
+		return strings.TrimRight(line, "\r\n"), err
+	}
+	// without a terminal, lines are read as they come and nothing but the answers is printed
+	restore, err := makeRaw(int(os.Stdin.Fd()))
+	interactive := err == nil
+	if interactive {
+		restore()
+		fmt.Printf("%d stations and %d connections loaded from %s, type help for the commands\n",
+			len(network.stations), len(network.sortedLinks()), flags.Arg(0))
+		editor := &lineEditor{in: in, out: os.Stdout, complete: sh.complete}
+		read = func() (string, error) {
+			// the terminal is raw only while a line is typed, so Ctrl-C still stops a long plan
+			restore, err := makeRaw(int(os.Stdin.Fd()))
+			if err != nil {
+				return "", err
+			}
+			defer restore()
+			editor.history = sh.history
+			return editor.readLine("> ")
+		}
+	}
+
+	for {
+		line, err := read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if len(sh.history) == 0 || sh.history[len(sh.history)-1] != line {
+			sh.history = append(sh.history, line)
+			if *historyFile != "" {
+				if err := appendLine(*historyFile, line); err != nil {
+					return err
+				}
+			}
+		}
+		quit, err := sh.execute(line)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "\033[41m ! Error ! \033[0m", describeError(err))
+		}
+		if quit {
+			return nil
+		}
+	}
+}
+
+// appendLine adds a line to the end of a file, creating the file when needed
+func appendLine(filename, line string) error {
+	file, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(file, line); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// runReplan plans the rest of a schedule from the turn a disruption hits it, see Replan
+func runReplan(args []string) error {
+	flags := flag.NewFlagSet("replan", flag.ContinueOnError)
+	turn := flags.Int("turn", 0, "number of turns of the schedule run before the disruption")
+	scheduleFile := flags.String("schedule", "", "file with the schedule being run, - for standard input; planned when not given")
+	var disruption Disruption
+	flags.Func("close", "station closed by the disruption (can be repeated)", func(name string) error {
+		disruption.Stations = append(disruption.Stations, name)
+		return nil
+	})
+	flags.Func("close-link", "connection `a-b` closed by the disruption, written like in map files (can be repeated)", func(value string) error {
+		start, end, err := parseLinkNames(value)
+		if err != nil {
+			return err
+		}
+		disruption.Links = append(disruption.Links, [2]string{start, end})
+		return nil
+	})
+	maxStations := flags.Int("max-stations", DefaultLoadOptions.MaxStations, "largest accepted number of stations, 0 for no limit")
+	ignoreCase := flags.Bool("ignore-case", false, "accept station names that differ only in case")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 4 {
+		return fmt.Errorf("usage: go run . replan [-turn n] [-schedule file] [-close station]... [-close-link a-b]... [-ignore-case] <network map file> <start station> <end station> <number of trains>")
+	}
+	trainCount, err := strconv.Atoi(flags.Arg(3))
+	if err != nil || trainCount <= 0 {
+		return fmt.Errorf("number of trains must be a valid positive integer")
+	}
+
+	network, err := LoadNetworkMapWithOptions(flags.Arg(0), LoadOptions{MaxStations: *maxStations})
+	if err != nil {
+		return fmt.Errorf("error loading network map: %v", err)
+	}
+	source, destination := flags.Arg(1), flags.Arg(2)
+	if *ignoreCase {
+		if source, destination, err = network.ResolveEndpoints(source, destination); err != nil {
+			return err
+		}
+		for i, name := range disruption.Stations {
+			if disruption.Stations[i], err = network.ResolveStation("closed", name); err != nil {
+				return err
+			}
+		}
+		for i, link := range disruption.Links {
+			for j, name := range link {
+				if disruption.Links[i][j], err = network.ResolveStation("", name); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	var schedule [][]string
+	switch *scheduleFile {
+	case "":
+		options := PlanOptions{ExploreOptions: ExploreOptions{Workers: runtime.NumCPU()}}
+		result, err := network.PlanContext(context.Background(), source, destination, trainCount, options)
+		if err != nil {
+			return err
+		}
+		schedule = scheduleMoves(result.Plan, trainCount)
+	case "-":
+		schedule, err = ReadSchedule(os.Stdin)
+	default:
+		var file *os.File
+		if file, err = os.Open(*scheduleFile); err == nil {
+			schedule, err = ReadSchedule(file)
+			file.Close()
+		}
+	}
+	if err != nil {
+		return err
+	}
+	state, err := network.StateAfter(schedule, source, trainCount, *turn)
+	if err != nil {
+		return err
+	}
+	continuation, err := network.Replan(source, destination, state, disruption)
+	if err != nil {
+		return err
+	}
+	writeMoves(os.Stdout, continuation.Moves)
+	fmt.Fprintf(os.Stderr, "\n%d turns after turn %d, %d in total where the schedule took %d\n",
+		len(continuation.Moves), continuation.Start, continuation.TotalTurns(), len(schedule))
+	return nil
+}
+
+// runServe serves the planner over HTTP, the given map files are registered under their names without extension
+// and reloaded when they change
+func runServe(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := flags.String("addr", "localhost:8080", "address to listen on")
+	maxStations := flags.Int("max-stations", DefaultLoadOptions.MaxStations, "largest accepted number of stations, 0 for no limit")
+	timeout := flags.Duration("timeout", 30*time.Second, "stop each plan after this long and answer with the best schedule found, 0 for no limit")
+	workers := flags.Int("workers", runtime.NumCPU(), "goroutines used by each plan")
+	watch := flags.Duration("watch", 2*time.Second, "how often the map files are checked for changes, 0 to never reload them")
+	cacheSize := flags.Int("cache-size", 1000, "most plans kept in memory, 0 to plan every request")
+	cacheDir := flags.String("cache", "", "directory where plans are also kept between runs")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	server := newMapServer(LoadOptions{MaxStations: *maxStations})
+	server.planTimeout, server.workers = *timeout, *workers
+	server.logger = log.New(os.Stderr, "", log.LstdFlags)
+	if *cacheSize > 0 {
+		cache, err := NewPlanCache(*cacheDir, *cacheSize)
+		if err != nil {
+			return err
+		}
+		server.cache = cache
+	}
+	for _, filename := range flags.Args() {
+		if err := server.RegisterFile(mapName(filename), filename); err != nil {
+			return err
+		}
+	}
+	if *watch > 0 {
+		go server.Watch(context.Background(), *watch)
+	}
+	fmt.Fprintf(os.Stderr, "serving %d maps on http://%s\n", len(flags.Args()), *addr)
+	return http.ListenAndServe(*addr, server.Handler())
+}
+
+// runShortest prints a single shortest route between two stations found with A*
+func runShortest(args []string) error {
+	flags := flag.NewFlagSet("shortest", flag.ContinueOnError)
+	weight := flags.String("weight", "hops", "route cost: hops counts tracks, distance adds up the straight line distances between stations")
+	maxStations := flags.Int("max-stations", DefaultLoadOptions.MaxStations, "largest accepted number of stations, 0 for no limit")
+	ignoreCase := flags.Bool("ignore-case", false, "accept station names that differ only in case")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 3 {
+		return fmt.Errorf("usage: go run . shortest [-weight hops|distance] [-ignore-case] <network map file> <start station> <end station>")
+	}
+
+	network, err := LoadNetworkMapWithOptions(flags.Arg(0), LoadOptions{MaxStations: *maxStations})
+	if err != nil {
+		return fmt.Errorf("error loading network map: %v", err)
+	}
+	source, destination := flags.Arg(1), flags.Arg(2)
+	if *ignoreCase {
+		if source, destination, err = network.ResolveEndpoints(source, destination); err != nil {
+			return err
+		}
+	}
+	switch *weight {
+	case "hops":
+		route, err := network.ShortestPath(source, destination)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d tracks: %s\n", len(route)-1, formatRoute(route))
+	case "distance":
+		route, err := network.ShortestDistancePath(source, destination)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%.2f: %s\n", route.Cost, formatRoute(route.Stations))
+	default:
+		return fmt.Errorf("unknown weight %s, expected hops or distance", *weight)
+	}
+	return nil
+}
+
+// runStats prints the statistics of a map and, given two stations, how many routes between them can run side by side
+func runStats(args []string) error {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	maxStations := flags.Int("max-stations", DefaultLoadOptions.MaxStations, "largest accepted number of stations, 0 for no limit")
+	ignoreCase := flags.Bool("ignore-case", false, "accept station names that differ only in case")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 && flags.NArg() != 3 {
+		return fmt.Errorf("usage: go run . stats [-ignore-case] <network map file> [<start station> <end station>]")
+	}
+
+	network, err := LoadNetworkMapWithOptions(flags.Arg(0), LoadOptions{MaxStations: *maxStations})
+	if err != nil {
+		return fmt.Errorf("error loading network map: %v", err)
+	}
+	writeStats(os.Stdout, network.Stats())
+
+	if flags.NArg() == 3 {
+		source, destination := flags.Arg(1), flags.Arg(2)
+		if *ignoreCase {
+			if source, destination, err = network.ResolveEndpoints(source, destination); err != nil {
+				return err
+			}
+		}
+		routes, err := network.MaxDisjointRoutes(source, destination)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("routes without shared stations from %s to %s: %d\n", formatName(source), formatName(destination), routes)
+	}
+	return nil
+}
+
+// writeStats writes the statistics of a network one line per figure
+func writeStats(w io.Writer, stats NetworkStats) {
+	fmt.Fprintf(w, "stations: %d\n", stats.Stations)
+	fmt.Fprintf(w, "connections: %d\n", stats.Connections)
+	fmt.Fprintln(w, "degree distribution:")
+	degrees := make([]int, 0, len(stats.Degrees))
+	for degree := range stats.Degrees {
+		degrees = append(degrees, degree)
+	}
+	sort.Ints(degrees)
+	for _, degree := range degrees {
+		fmt.Fprintf(w, "  %d connections: %d stations\n", degree, stats.Degrees[degree])
+	}
+	fmt.Fprintf(w, "components: %d", len(stats.Components))
+	if len(stats.Components) > 1 {
+		fmt.Fprintf(w, " (largest %d stations, smallest %d)", len(stats.Components[0]), len(stats.Components[len(stats.Components)-1]))
+	}
+	fmt.Fprintln(w)
+	if stats.DiameterEstimated {
+		fmt.Fprintf(w, "diameter: at least %d tracks (estimated on more than %d stations)\n", stats.Diameter, exactDiameterStations)
+	} else {
+		fmt.Fprintf(w, "diameter: %d tracks\n", stats.Diameter)
+	}
+	var points []string
+	for _, name := range stats.ArticulationPoints {
+		points = append(points, formatName(name))
+	}
+	fmt.Fprintf(w, "articulation points: %d%s\n", len(points), listSuffix(points))
+	var bridges []string
+	for _, bridge := range stats.Bridges {
+		bridges = append(bridges, formatRoute(bridge[:]))
+	}
+	fmt.Fprintf(w, "bridges: %d%s\n", len(bridges), listSuffix(bridges))
+}
+
+// listSuffix writes names as " (a, b)" after a count, or nothing when there are none
+func listSuffix(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(names, ", ") + ")"
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// NoRouteError is returned when source and destination are in parts of the network that no track joins.
+// Its message is the usual "no routes found from start to end", Diagnosis tells what is missing
+type NoRouteError struct {
+	Source, Destination string
+	// SourceComponent and DestinationComponent are the stations reachable from each end, sorted by name
+	SourceComponent, DestinationComponent []string
+	// Nearest are the stations reachable from the source that lie closest to the destination, closest first
+	Nearest []string
+	// MissingLinks are suggested connections that would join the two parts, any one of them is enough.
+	// They are the shortest between the stations of each part closest to the other end, on large maps
+	// a shorter one may exist elsewhere
+	MissingLinks [][2]string
+}
+
+func (err *NoRouteError) Error() string {
+	return "no routes found from start to end"
+}
+
+// Diagnosis describes both parts of the network and how they could be joined
+func (err *NoRouteError) Diagnosis() string {
+	var b strings.Builder
+	for _, end := range []struct {
+		name      string
+		component []string
+	}{{err.Source, err.SourceComponent}, {err.Destination, err.DestinationComponent}} {
+		if len(end.component) == 1 {
+			fmt.Fprintf(&b, "%s has no connections\n", formatName(end.name))
+		} else {
+			fmt.Fprintf(&b, "%s is in a part of %d stations: %s\n", formatName(end.name), len(end.component), summarizeNames(end.component))
+		}
+	}
+	fmt.Fprintf(&b, "stations reachable from %s closest to %s: %s\n", formatName(err.Source), formatName(err.Destination), summarizeNames(err.Nearest))
+	var links []string
+	for _, link := range err.MissingLinks {
+		links = append(links, formatRoute(link[:]))
+	}
+	fmt.Fprintf(&b, "any of these connections would join them: %s", strings.Join(links, ", "))
+	return b.String()
+}
+
+// summarizeNames lists up to ten names
+func summarizeNames(names []string) string {
+	var formatted []string
+	for i, name := range names {
+		if i == 10 {
+			formatted = append(formatted, fmt.Sprintf("and %d more", len(names)-i))
+			break
+		}
+		formatted = append(formatted, formatName(name))
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// diagnosisLimit is the number of nearest stations and missing connections suggested
+const diagnosisLimit = 3
+
+// checkConnected returns a *NoRouteError when no track joins the parts of the network the two stations are in.
+// The stations must exist
+func (network *RailNetwork) checkConnected(source, destination string) error {
+	graph := network.Compact()
+	from, to := graph.ids[source], graph.ids[destination]
+	if graph.components[from] == graph.components[to] {
+		return nil
+	}
+
+	err := &NoRouteError{Source: source, Destination: destination}
+	var sourceIDs, destinationIDs []int32
+	for id, component := range graph.components {
+		switch component {
+		case graph.components[from]:
+			sourceIDs = append(sourceIDs, int32(id))
+			err.SourceComponent = append(err.SourceComponent, graph.names[id])
+		case graph.components[to]:
+			destinationIDs = append(destinationIDs, int32(id))
+			err.DestinationComponent = append(err.DestinationComponent, graph.names[id])
+		}
+	}
+
+	// stations without coordinates count as far away from everything
+	distance := func(a, b int32) float64 {
+		first, second := network.stations[graph.names[a]], network.stations[graph.names[b]]
+		if !first.placed || !second.placed {
+			return math.Inf(1)
+		}
+		return math.Hypot(float64(first.x-second.x), float64(first.y-second.y))
+	}
+	// closest orders ids by distance to target and then by name, which is the ID order
+	closest := func(ids []int32, target int32) []int32 {
+		sorted := append([]int32{}, ids...)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return distance(sorted[i], target) < distance(sorted[j], target)
+		})
+		return sorted
+	}
+
+	sourceIDs = closest(sourceIDs, to)
+	for _, id := range sourceIDs[:min(diagnosisLimit, len(sourceIDs))] {
+		err.Nearest = append(err.Nearest, graph.names[id])
+	}
+
+	// comparing every pair would be too slow on large maps, the stations of each part closest to
+	// the other end are where short connections are likely to start
+	candidates := sourceIDs[:min(100, len(sourceIDs))]
+	targets := closest(destinationIDs, from)[:min(100, len(destinationIDs))]
+	type pair struct {
+		a, b     int32
+		distance float64
+	}
+	var pairs []pair
+	for _, a := range candidates {
+		for _, b := range targets {
+			pairs = append(pairs, pair{a, b, distance(a, b)})
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		if pairs[i].distance != pairs[j].distance {
+			return pairs[i].distance < pairs[j].distance
+		}
+		if pairs[i].a != pairs[j].a {
+			return pairs[i].a < pairs[j].a
+		}
+		return pairs[i].b < pairs[j].b
+	})
+	for _, pair := range pairs[:min(diagnosisLimit, len(pairs))] {
+		err.MissingLinks = append(err.MissingLinks, [2]string{graph.names[pair.a], graph.names[pair.b]})
+	}
+	return err
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// TestNoRouteError tests the diagnosis for two parts of a network that no track joins
+func TestNoRouteError(t *testing.T) {
+	network := NewRailNetwork()
+	coordinates := map[string][2]int{"a": {0, 0}, "b": {1, 0}, "c": {2, 0}, "x": {4, 1}, "y": {9, 0}, "z": {5, 5}}
+	for name, xy := range coordinates {
+		network.AddLocation(name)
+		network.SetCoordinates(name, xy[0], xy[1])
+	}
+	network.AddLink("a", "b")
+	network.AddLink("b", "c")
+	network.AddLink("x", "y")
+	network.AddLink("y", "z")
+
+	_, err := network.ExplorePaths("a", "z")
+	var noRoute *NoRouteError
+	if !errors.As(err, &noRoute) {
+		t.Fatalf("Expected a NoRouteError, got: %v", err)
+	}
+	if err.Error() != "no routes found from start to end" {
+		t.Fatalf("Expected 'no routes found from start to end' error, got: %v", err)
+	}
+	expected := &NoRouteError{
+		Source:               "a",
+		Destination:          "z",
+		SourceComponent:      []string{"a", "b", "c"},
+		DestinationComponent: []string{"x", "y", "z"},
+		Nearest:              []string{"c", "b", "a"},
+		MissingLinks:         [][2]string{{"c", "x"}, {"b", "x"}, {"a", "x"}},
+	}
+	if !reflect.DeepEqual(noRoute, expected) {
+		t.Fatalf("Expected %+v, got %+v", *expected, *noRoute)
+	}
+
+	diagnosis := `a is in a part of 3 stations: a, b, c
+z is in a part of 3 stations: x, y, z
+stations reachable from a closest to z: c, b, a
+any of these connections would join them: c-x, b-x, a-x`
+	if noRoute.Diagnosis() != diagnosis {
+		t.Fatalf("Expected\n%s\ngot\n%s", diagnosis, noRoute.Diagnosis())
+	}
+}
+
+// TestNoRouteError_EveryQuery tests that every route query reports the missing connection the same way
+func TestNoRouteError_EveryQuery(t *testing.T) {
+	network := gridNetwork(2, 2)
+	network.AddLocation("island")
+	network.SetCoordinates("island", 100, 100)
+
+	_, shortestErr := network.ShortestPath("0_0", "island")
+	_, pathsErr := network.KShortestPaths("0_0", "island", 2, PathOptions{})
+	_, boundErr := network.TurnLowerBound("0_0", "island", 2)
+	_, planErr := network.Plan("0_0", "island", 2)
+	for _, err := range []error{shortestErr, pathsErr, boundErr, planErr} {
+		var noRoute *NoRouteError
+		if !errors.As(err, &noRoute) || !reflect.DeepEqual(noRoute.MissingLinks[0], [2]string{"1_1", "island"}) {
+			t.Fatalf("Expected the missing connection 1_1-island, got: %v", err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// planExplanation tells which candidate route sets the planner compared and why it chose one of them
+type planExplanation struct {
+	Candidates []candidateReport `json:"candidates"`
+	Chosen     int               `json:"chosen"`   // index into Candidates
+	RunnerUp   int               `json:"runnerUp"` // index into Candidates, -1 when there was no other candidate
+	Reason     string            `json:"reason"`
+}
+
+// candidateReport is a route set considered by the planner with its train distribution and turns
+type candidateReport struct {
+	Routes []routeReport `json:"routes"`
+	Turns  int           `json:"turns"`
+}
+
+// explainPlan compares the candidates of a result that was planned with PlanOptions.Explain
+func explainPlan(result PlanResult) planExplanation {
+	explanation := planExplanation{Candidates: make([]candidateReport, len(result.Candidates)), RunnerUp: -1}
+	for i, plan := range result.Candidates {
+		explanation.Candidates[i] = candidateReport{Routes: planRoutes(plan, true), Turns: plan.totalTurns}
+	}
+	explanation.Chosen = bestPlanIndex(result.Candidates)
+
+	// the runner-up is the best of the rest, the first one again when several are equally good
+	for i, plan := range result.Candidates {
+		if i != explanation.Chosen && (explanation.RunnerUp < 0 || plan.totalTurns < result.Candidates[explanation.RunnerUp].totalTurns) {
+			explanation.RunnerUp = i
+		}
+	}
+	explanation.Reason = explainChoice(result.Candidates, explanation.Chosen, explanation.RunnerUp)
+	return explanation
+}
+
+// explainChoice says in words why the chosen candidate beat the runner-up
+func explainChoice(candidates []routePlan, chosen, runnerUp int) string {
+	winner := candidates[chosen]
+	if runnerUp < 0 {
+		return fmt.Sprintf("it was the only candidate, %d turns", winner.totalTurns)
+	}
+	other := candidates[runnerUp]
+	if winner.totalTurns < other.totalTurns {
+		return fmt.Sprintf("it takes %d turns, %d fewer than the runner-up", winner.totalTurns, other.totalTurns-winner.totalTurns)
+	}
+	// AllocateTrains keeps the first of equally good candidates, SelectOptimalCombos orders them
+	// by their first route and, for the same first route, puts smaller sets first
+	if routeLess(winner.routes[0], other.routes[0]) {
+		return fmt.Sprintf("it ties with the runner-up at %d turns and comes first because its first route %s sorts before %s",
+			winner.totalTurns, formatRoute(winner.routes[0]), formatRoute(other.routes[0]))
+	}
+	if len(winner.routes) < len(other.routes) {
+		return fmt.Sprintf("it ties with the runner-up at %d turns and comes first because it needs %d routes instead of %d",
+			winner.totalTurns, len(winner.routes), len(other.routes))
+	}
+	return fmt.Sprintf("it ties with the runner-up at %d turns, starts with the same route and has as many routes, the combination built first is kept",
+		winner.totalTurns)
+}
+
+// planRoutes lists the routes of a plan with their number of trains, leaving out unused routes unless all is set
+func planRoutes(plan routePlan, all bool) []routeReport {
+	routes := []routeReport{}
+	for i, route := range plan.routes {
+		if trains := plan.trainDistribution[i]; trains > 0 || all {
+			routes = append(routes, routeReport{Stations: route, Trains: trains})
+		}
+	}
+	return routes
+}
+
+// writeExplanation writes the explanation as text, candidates numbered from 1
+func writeExplanation(w io.Writer, explanation planExplanation) {
+	fmt.Fprintln(w, "Candidates considered:")
+	for i, candidate := range explanation.Candidates {
+		var routes []string
+		for _, route := range candidate.Routes {
+			routes = append(routes, fmt.Sprintf("%s (%d trains)", formatRoute(route.Stations), route.Trains))
+		}
+		fmt.Fprintf(w, "%4d. %d turns: %s\n", i+1, candidate.Turns, strings.Join(routes, ", "))
+	}
+	fmt.Fprintf(w, "Chosen: candidate %d\n", explanation.Chosen+1)
+	if explanation.RunnerUp >= 0 {
+		fmt.Fprintf(w, "Runner-up: candidate %d\n", explanation.RunnerUp+1)
+	}
+	fmt.Fprintf(w, "Reason: %s\n", explanation.Reason)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// planExplained plans a query with PlanOptions.Explain set
+func planExplained(t *testing.T, file, source, destination string, trains int) PlanResult {
+	t.Helper()
+	network, err := LoadNetworkMap(file)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	result, err := network.PlanContext(context.Background(), source, destination, trains, PlanOptions{Explain: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return result
+}
+
+// TestExplainPlan tests the text explanation when the chosen candidate takes fewer turns
+func TestExplainPlan(t *testing.T) {
+	result := planExplained(t, "network3.map", "waterloo", "st_pancras", 2)
+
+	var buf bytes.Buffer
+	writeExplanation(&buf, explainPlan(result))
+	expected := `Candidates considered:
+   1. 3 turns: waterloo-euston-st_pancras (2 trains)
+   2. 2 turns: waterloo-euston-st_pancras (1 trains), waterloo-victoria-st_pancras (1 trains)
+Chosen: candidate 2
+Runner-up: candidate 1
+Reason: it takes 2 turns, 1 fewer than the runner-up
+`
+	if buf.String() != expected {
+		t.Fatalf("Expected\n%s\ngot\n%s", expected, buf.String())
+	}
+}
+
+// TestExplainPlan_Tie tests that the explanation matches the plan AllocateTrains picks among equally good candidates
+func TestExplainPlan_Tie(t *testing.T) {
+	result := planExplained(t, "network7.map", "small", "large", 9)
+	explanation := explainPlan(result)
+
+	chosen := result.Candidates[explanation.Chosen]
+	if !reflect.DeepEqual(chosen, result.Plan) {
+		t.Fatalf("Expected the chosen candidate to be the plan, got %v", chosen.routes)
+	}
+	if explanation.Candidates[explanation.RunnerUp].Turns != chosen.totalTurns {
+		t.Fatalf("Expected a runner-up with %d turns, got %+v", chosen.totalTurns, explanation.Candidates[explanation.RunnerUp])
+	}
+	if !strings.HasPrefix(explanation.Reason, "it ties with the runner-up at 8 turns") {
+		t.Fatalf("Expected a tie to be explained, got %s", explanation.Reason)
+	}
+	for _, candidate := range explanation.Candidates {
+		if candidate.Turns < chosen.totalTurns {
+			t.Fatalf("Candidate %+v beats the chosen plan", candidate)
+		}
+	}
+}
+
+// TestPlanContext_WithoutExplain tests that candidates are only kept when asked for
+func TestPlanContext_WithoutExplain(t *testing.T) {
+	network, err := LoadNetworkMap("network3.map")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	result, err := network.PlanContext(context.Background(), "waterloo", "st_pancras", 2, PlanOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	WritePlanJSON(&buf, result, "waterloo", "st_pancras", 2)
+	if result.Candidates != nil || strings.Contains(buf.String(), "explanation") {
+		t.Fatalf("Expected no explanation, got %s", buf.String())
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// GenerateOptions describes a random network for GenerateNetwork
+type GenerateOptions struct {
+	Stations int
+	// Degree is the average number of connections per station, topologies that cannot reach it get as close as they can
+	Degree float64
+	// Topology is one of grid, planar, random-geometric or scale-free
+	Topology string
+	// Seed makes the network reproducible, the same options always give the same network
+	Seed int64
+}
+
+// GenerateNetwork builds a random network with unique station names and coordinates.
+// Grid, planar and scale-free networks are always connected, random geometric ones
+// connect the closest stations and may fall apart into clusters at low degrees
+func GenerateNetwork(options GenerateOptions) (*RailNetwork, error) {
+	if options.Stations < 2 {
+		return nil, errors.New("number of stations must be at least 2")
+	}
+	if options.Degree <= 0 {
+		return nil, errors.New("average degree must be positive")
+	}
+
+	rng := rand.New(rand.NewSource(options.Seed))
+	n := options.Stations
+	target := int(math.Round(float64(n) * options.Degree / 2))
+
+	var points [][2]int
+	var edges [][2]int
+	switch options.Topology {
+	case "grid":
+		points = gridPoints(n, 1, 0, rng)
+		edges = connectTree(n, gridEdges(n, false, rng), target, rng)
+	case "planar":
+		// jitter stays well inside the cells, so the triangulated grid keeps its tracks from crossing
+		points = gridPoints(n, 10, 4, rng)
+		edges = connectTree(n, gridEdges(n, true, rng), target, rng)
+	case "random-geometric":
+		points = randomPoints(n, rng)
+		edges = closestPairs(points, target)
+	case "scale-free":
+		points = randomPoints(n, rng)
+		edges = preferentialAttachment(n, int(math.Max(1, math.Round(options.Degree/2))), rng)
+	default:
+		return nil, fmt.Errorf("unknown topology %s, expected grid, planar, random-geometric or scale-free", options.Topology)
+	}
+
+	network := NewRailNetwork()
+	names := make([]string, n)
+	width := len(fmt.Sprint(n - 1))
+	for i := range names {
+		// zero padding keeps the lexicographic order of the names the same as the numeric one
+		names[i] = fmt.Sprintf("s%0*d", width, i)
+		network.AddLocation(names[i])
+		network.SetCoordinates(names[i], points[i][0], points[i][1])
+	}
+	for _, edge := range edges {
+		if err := network.AddLink(names[edge[0]], names[edge[1]]); err != nil {
+			return nil, err
+		}
+	}
+	network.header = []string{fmt.Sprintf("generated with -topology %s -stations %d -degree %g -seed %d",
+		options.Topology, options.Stations, options.Degree, options.Seed)}
+	return network, nil
+}
+
+// gridWidth returns the number of columns of the square grid that holds n stations
+func gridWidth(n int) int {
+	return int(math.Ceil(math.Sqrt(float64(n))))
+}
+
+// gridPoints places station i in column i%width and row i/width, spacing apart and moved by up to jitter
+func gridPoints(n, spacing, jitter int, rng *rand.Rand) [][2]int {
+	width := gridWidth(n)
+	points := make([][2]int, n)
+	for i := range points {
+		points[i] = [2]int{i%width*spacing + rng.Intn(jitter+1), i/width*spacing + rng.Intn(jitter+1)}
+	}
+	return points
+}
+
+// gridEdges returns the tracks between neighboring grid cells, with diagonal set one diagonal of every square as well
+func gridEdges(n int, diagonal bool, rng *rand.Rand) [][2]int {
+	width := gridWidth(n)
+	var edges [][2]int
+	for i := 0; i < n; i++ {
+		right, below := i+1, i+width
+		if i%width+1 < width && right < n {
+			edges = append(edges, [2]int{i, right})
+		}
+		if below < n {
+			edges = append(edges, [2]int{i, below})
+		}
+		if diagonal && i%width+1 < width && below+1 < n {
+			if rng.Intn(2) == 0 {
+				edges = append(edges, [2]int{i, below + 1})
+			} else {
+				edges = append(edges, [2]int{right, below})
+			}
+		}
+	}
+	return edges
+}
+
+// connectTree picks a random spanning tree out of the candidate tracks and then random other
+// candidates until there are target tracks or no candidates left
+func connectTree(n int, candidates [][2]int, target int, rng *rand.Rand) [][2]int {
+	rng.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+
+	parent := make([]int, n)
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	var edges, rest [][2]int
+	for _, edge := range candidates {
+		a, b := find(edge[0]), find(edge[1])
+		if a != b {
+			parent[a] = b
+			edges = append(edges, edge)
+		} else {
+			rest = append(rest, edge)
+		}
+	}
+	for _, edge := range rest {
+		if len(edges) >= target {
+			break
+		}
+		edges = append(edges, edge)
+	}
+	return edges
+}
+
+// randomPoints returns n distinct points spread over a square with room for about 100 points per station
+func randomPoints(n int, rng *rand.Rand) [][2]int {
+	side := gridWidth(n) * 10
+	used := make(map[[2]int]bool, n)
+	points := make([][2]int, 0, n)
+	for len(points) < n {
+		point := [2]int{rng.Intn(side), rng.Intn(side)}
+		if !used[point] {
+			used[point] = true
+			points = append(points, point)
+		}
+	}
+	return points
+}
+
+// closestPairs connects the target closest pairs of points, which makes a random geometric graph
+// whose radius is just large enough for the wanted degree
+func closestPairs(points [][2]int, target int) [][2]int {
+	n := len(points)
+	side := float64(gridWidth(n) * 10)
+	// a radius twice the expected one finds enough pairs in all but the most uneven spreads
+	radius := 2 * math.Sqrt(float64(2*target)*side*side/(math.Pi*float64(n)))
+
+	for {
+		// stations are bucketed into cells of radius size, pairs within radius are in neighboring cells
+		cell := func(point [2]int) [2]int {
+			return [2]int{int(float64(point[0]) / radius), int(float64(point[1]) / radius)}
+		}
+		buckets := make(map[[2]int][]int)
+		for i, point := range points {
+			buckets[cell(point)] = append(buckets[cell(point)], i)
+		}
+
+		type pair struct {
+			edge   [2]int
+			length float64
+		}
+		var pairs []pair
+		for i, point := range points {
+			c := cell(point)
+			for dx := -1; dx <= 1; dx++ {
+				for dy := -1; dy <= 1; dy++ {
+					for _, j := range buckets[[2]int{c[0] + dx, c[1] + dy}] {
+						length := math.Hypot(float64(point[0]-points[j][0]), float64(point[1]-points[j][1]))
+						if i < j && length <= radius {
+							pairs = append(pairs, pair{[2]int{i, j}, length})
+						}
+					}
+				}
+			}
+		}
+
+		if len(pairs) < target && radius < side*math.Sqrt2 {
+			radius *= 2
+			continue
+		}
+		sort.Slice(pairs, func(a, b int) bool {
+			if pairs[a].length != pairs[b].length {
+				return pairs[a].length < pairs[b].length
+			}
+			if pairs[a].edge[0] != pairs[b].edge[0] {
+				return pairs[a].edge[0] < pairs[b].edge[0]
+			}
+			return pairs[a].edge[1] < pairs[b].edge[1]
+		})
+		edges := make([][2]int, 0, target)
+		for i := 0; i < len(pairs) && i < target; i++ {
+			edges = append(edges, pairs[i].edge)
+		}
+		return edges
+	}
+}
+
+// preferentialAttachment builds a Barabási-Albert network: every new station connects to m earlier
+// stations, picked with a probability that grows with the number of connections they already have
+func preferentialAttachment(n, m int, rng *rand.Rand) [][2]int {
+	if m >= n {
+		m = n - 1
+	}
+	var edges [][2]int
+	var ends []int // every station appears once per connection it has
+	for i := 0; i <= m; i++ {
+		for j := 0; j < i; j++ {
+			edges = append(edges, [2]int{j, i})
+			ends = append(ends, i, j)
+		}
+	}
+	for i := m + 1; i < n; i++ {
+		chosen := make(map[int]bool, m)
+		var picked []int
+		for len(picked) < m {
+			j := ends[rng.Intn(len(ends))]
+			if !chosen[j] {
+				chosen[j] = true
+				picked = append(picked, j)
+			}
+		}
+		for _, j := range picked {
+			edges = append(edges, [2]int{j, i})
+			ends = append(ends, i, j)
+		}
+	}
+	return edges
+}
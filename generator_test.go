@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestGenerateNetwork tests that every topology gives a loadable, reproducible map of the wanted size
+func TestGenerateNetwork(t *testing.T) {
+	for _, topology := range []string{"grid", "planar", "random-geometric", "scale-free"} {
+		options := GenerateOptions{Stations: 300, Degree: 3, Topology: topology, Seed: 42}
+		network, err := GenerateNetwork(options)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", topology, err)
+		}
+		reloaded, written := writeAndReload(t, network)
+		if len(reloaded.stations) != options.Stations {
+			t.Fatalf("%s: expected %d stations, got %d", topology, options.Stations, len(reloaded.stations))
+		}
+
+		tracks := len(reloaded.sortedLinks())
+		if degree := 2 * float64(tracks) / float64(options.Stations); degree < 2.5 || degree > 4.5 {
+			t.Fatalf("%s: expected an average degree near %v, got %v", topology, options.Degree, degree)
+		}
+		if topology != "random-geometric" && len(reloaded.ConnectedComponents()) != 1 {
+			t.Fatalf("%s: expected a connected network", topology)
+		}
+
+		again, err := GenerateNetwork(options)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", topology, err)
+		}
+		var buf bytes.Buffer
+		again.WriteNetworkMap(&buf)
+		if !bytes.Equal(buf.Bytes(), written) {
+			t.Fatalf("%s: the same seed gave a different map", topology)
+		}
+	}
+}
+
+// TestGenerateNetwork_Planar tests that no two tracks of a planar map cross
+func TestGenerateNetwork_Planar(t *testing.T) {
+	network, err := GenerateNetwork(GenerateOptions{Stations: 200, Degree: 5, Topology: "planar", Seed: 7})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	point := func(name string) [2]int {
+		return [2]int{network.stations[name].x, network.stations[name].y}
+	}
+	// orientation is the sign of the cross product of b-a and c-a
+	orientation := func(a, b, c [2]int) int {
+		cross := (b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])
+		if cross > 0 {
+			return 1
+		} else if cross < 0 {
+			return -1
+		}
+		return 0
+	}
+	links := network.sortedLinks()
+	for i, first := range links {
+		for _, second := range links[i+1:] {
+			if first[0] == second[0] || first[0] == second[1] || first[1] == second[0] || first[1] == second[1] {
+				continue
+			}
+			a, b, c, d := point(first[0]), point(first[1]), point(second[0]), point(second[1])
+			if orientation(a, b, c)*orientation(a, b, d) < 0 && orientation(c, d, a)*orientation(c, d, b) < 0 {
+				t.Fatalf("Tracks %v and %v cross", first, second)
+			}
+		}
+	}
+}
+
+// TestGenerateNetwork_Errors tests the errors for invalid options
+func TestGenerateNetwork_Errors(t *testing.T) {
+	tests := []struct {
+		options  GenerateOptions
+		expected string
+	}{
+		{GenerateOptions{Stations: 1, Degree: 3, Topology: "grid"}, "number of stations must be at least 2"},
+		{GenerateOptions{Stations: 10, Degree: 0, Topology: "grid"}, "average degree must be positive"},
+		{GenerateOptions{Stations: 10, Degree: 3, Topology: "ring"}, "unknown topology ring, expected grid, planar, random-geometric or scale-free"},
+	}
+	for _, test := range tests {
+		_, err := GenerateNetwork(test.options)
+		if err == nil || err.Error() != test.expected {
+			t.Fatalf("Expected '%s' error, got: %v", test.expected, err)
+		}
+	}
+}
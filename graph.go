@@ -0,0 +1,125 @@
+package main
+
+import (
+	"sort"
+	"sync"
+)
+
+// compactGraph is an integer indexed copy of the network's tracks in compressed sparse row form.
+// Station IDs follow the lexicographic order of the names, the neighbors of station i are
+// adjacency[offsets[i]:offsets[i+1]] and are sorted by ID as well
+type compactGraph struct {
+	ids        map[string]int32
+	names      []string
+	offsets    []int32
+	adjacency  []int32
+	components []int32 // component of every station, numbered in the order of their lowest station ID
+
+	hashOnce sync.Once // the content hash is computed on first use, see ContentHash
+	hash     string
+}
+
+// Compact builds the compact graph of the network, or returns the one built earlier
+// if the network has not changed since
+func (network *RailNetwork) Compact() *compactGraph {
+	if network.graph != nil {
+		return network.graph
+	}
+
+	graph := &compactGraph{
+		ids:     make(map[string]int32, len(network.stations)),
+		names:   network.sortedStationNames(),
+		offsets: make([]int32, len(network.stations)+1),
+	}
+	for id, name := range graph.names {
+		graph.ids[name] = int32(id)
+	}
+
+	total := 0
+	for id, name := range graph.names {
+		total += len(network.links[name])
+		graph.offsets[id+1] = int32(total)
+	}
+	graph.adjacency = make([]int32, total)
+	for id, name := range graph.names {
+		neighbors := graph.adjacency[graph.offsets[id]:graph.offsets[id+1]]
+		i := 0
+		for neighbor := range network.links[name] {
+			neighbors[i] = graph.ids[neighbor]
+			i++
+		}
+		sort.Slice(neighbors, func(a, b int) bool { return neighbors[a] < neighbors[b] })
+	}
+
+	graph.labelComponents()
+
+	network.graph = graph
+	return graph
+}
+
+// labelComponents numbers the groups of stations that are reachable from each other
+func (graph *compactGraph) labelComponents() {
+	graph.components = make([]int32, graph.stationCount())
+	for i := range graph.components {
+		graph.components[i] = -1
+	}
+	count := int32(0)
+	for root := range graph.components {
+		if graph.components[root] >= 0 {
+			continue
+		}
+		graph.components[root] = count
+		queue := []int32{int32(root)}
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			for _, neighbor := range graph.neighbors(current) {
+				if graph.components[neighbor] < 0 {
+					graph.components[neighbor] = count
+					queue = append(queue, neighbor)
+				}
+			}
+		}
+		count++
+	}
+}
+
+// neighbors returns the IDs of the stations connected to station id
+func (graph *compactGraph) neighbors(id int32) []int32 {
+	return graph.adjacency[graph.offsets[id]:graph.offsets[id+1]]
+}
+
+// stationCount returns the number of stations in the graph
+func (graph *compactGraph) stationCount() int {
+	return len(graph.names)
+}
+
+// route converts a path of station IDs into station names
+func (graph *compactGraph) route(path []int32) []string {
+	route := make([]string, len(path))
+	for i, id := range path {
+		route[i] = graph.names[id]
+	}
+	return route
+}
+
+// distancesTo returns for every station the number of tracks on the shortest way to target, -1 if there is none
+func (graph *compactGraph) distancesTo(target int32) []int32 {
+	distances := make([]int32, graph.stationCount())
+	for i := range distances {
+		distances[i] = -1
+	}
+	distances[target] = 0
+	queue := []int32{target}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, neighbor := range graph.neighbors(current) {
+			if distances[neighbor] < 0 {
+				distances[neighbor] = distances[current] + 1
+				queue = append(queue, neighbor)
+			}
+		}
+	}
+	return distances
+}
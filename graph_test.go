@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestCompact tests that station IDs follow name order and neighbors are sorted
+func TestCompact(t *testing.T) {
+	network := NewRailNetwork()
+	for _, name := range []string{"part", "beethoven", "mozart", "verdi"} {
+		network.AddLocation(name)
+	}
+	network.AddLink("part", "verdi")
+	network.AddLink("part", "beethoven")
+	network.AddLink("mozart", "part")
+
+	graph := network.Compact()
+	if !reflect.DeepEqual(graph.names, []string{"beethoven", "mozart", "part", "verdi"}) {
+		t.Fatalf("Expected stations interned in name order, got %v", graph.names)
+	}
+	if neighbors := graph.neighbors(graph.ids["part"]); !reflect.DeepEqual(neighbors, []int32{0, 1, 3}) {
+		t.Fatalf("Expected sorted neighbors [0 1 3] for part, got %v", neighbors)
+	}
+	if neighbors := graph.neighbors(graph.ids["beethoven"]); !reflect.DeepEqual(neighbors, []int32{2}) {
+		t.Fatalf("Expected neighbors [2] for beethoven, got %v", neighbors)
+	}
+	if network.Compact() != graph {
+		t.Fatalf("Expected the compact graph to be reused while the network does not change")
+	}
+
+	network.AddLink("beethoven", "verdi")
+	if network.Compact() == graph {
+		t.Fatalf("Expected AddLink to drop the stale compact graph")
+	}
+	if neighbors := network.Compact().neighbors(0); !reflect.DeepEqual(neighbors, []int32{2, 3}) {
+		t.Fatalf("Expected neighbors [2 3] for beethoven after AddLink, got %v", neighbors)
+	}
+}
+
+// TestLoadNetworkMapWithOptions_StationLimit tests that the station limit is configurable
+func TestLoadNetworkMapWithOptions_StationLimit(t *testing.T) {
+	if _, err := LoadNetworkMapWithOptions("network_err9.map", LoadOptions{MaxStations: 0}); err != nil {
+		t.Fatalf("Expected no limit with MaxStations 0, got: %v", err)
+	}
+	_, err := LoadNetworkMapWithOptions("network8.map", LoadOptions{MaxStations: 5})
+	if err == nil || err.Error() != "map contains more than 5 stations" {
+		t.Fatalf("Expected 'map contains more than 5 stations' error, got: %v", err)
+	}
+}
+
+// TestLoadNetworkMapWithOptions_LargeMap tests loading and planning on a line of 200000 stations
+func TestLoadNetworkMapWithOptions_LargeMap(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping large map in short mode")
+	}
+	const count = 200000
+	var contents strings.Builder
+	contents.WriteString("stations:\n")
+	for i := 0; i < count; i++ {
+		fmt.Fprintf(&contents, "s%d,%d,%d\n", i, i%1000, i/1000)
+	}
+	contents.WriteString("\nconnections:\n")
+	for i := 1; i < count; i++ {
+		fmt.Fprintf(&contents, "s%d-s%d\n", i-1, i)
+	}
+	path := filepath.Join(t.TempDir(), "large.map")
+	if err := os.WriteFile(path, []byte(contents.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	network, err := LoadNetworkMapWithOptions(path, LoadOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	routes, err := network.ExplorePaths("s0", fmt.Sprintf("s%d", count-1))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(routes) != 1 || len(routes[0]) != count {
+		t.Fatalf("Expected one route through all %d stations, got %d routes", count, len(routes))
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// lineEditor reads lines typed on a terminal in raw mode. It moves the cursor with the arrow keys,
+// Home, End and the usual Ctrl keys, browses earlier lines with up and down and completes words with Tab
+type lineEditor struct {
+	in      *bufio.Reader
+	out     io.Writer
+	history []string
+	// complete returns where the word before the cursor starts in head and the words it may become
+	complete func(head string) (int, []string)
+}
+
+// editedLine is the text being edited and the cursor position in it, counted in runes
+type editedLine struct {
+	prompt string
+	text   []rune
+	cursor int
+}
+
+func (line *editedLine) redraw(out io.Writer) {
+	fmt.Fprintf(out, "\r%s%s\033[K", line.prompt, string(line.text))
+	if back := len(line.text) - line.cursor; back > 0 {
+		fmt.Fprintf(out, "\033[%dD", back)
+	}
+}
+
+func (line *editedLine) set(text string) {
+	line.text = []rune(text)
+	line.cursor = len(line.text)
+}
+
+// readLine shows the prompt and returns the line once Enter is pressed, io.EOF for Ctrl-D on an empty line
+func (editor *lineEditor) readLine(prompt string) (string, error) {
+	line := &editedLine{prompt: prompt}
+	line.redraw(editor.out)
+	browsed := len(editor.history) // index of the history line shown, len(history) for the new line
+	var unsent string              // the new line while earlier ones are browsed
+	tabbed := false                // the previous key was Tab, a second Tab lists the choices
+
+	for {
+		key, _, err := editor.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		wasTabbed := tabbed
+		tabbed = false
+
+		switch key {
+		case '\r', '\n':
+			fmt.Fprint(editor.out, "\r\n")
+			return string(line.text), nil
+		case 3: // Ctrl-C drops the line
+			fmt.Fprint(editor.out, "^C\r\n")
+			line.set("")
+			browsed = len(editor.history)
+		case 4: // Ctrl-D
+			if len(line.text) == 0 {
+				fmt.Fprint(editor.out, "\r\n")
+				return "", io.EOF
+			}
+			line.deleteAt(line.cursor)
+		case 127, 8: // Backspace
+			if line.cursor > 0 {
+				line.cursor--
+				line.deleteAt(line.cursor)
+			}
+		case 1: // Ctrl-A
+			line.cursor = 0
+		case 5: // Ctrl-E
+			line.cursor = len(line.text)
+		case 2: // Ctrl-B
+			line.cursor = max(0, line.cursor-1)
+		case 6: // Ctrl-F
+			line.cursor = min(len(line.text), line.cursor+1)
+		case 11: // Ctrl-K
+			line.text = line.text[:line.cursor]
+		case 21: // Ctrl-U
+			line.text = line.text[line.cursor:]
+			line.cursor = 0
+		case 16, 14: // Ctrl-P and Ctrl-N
+			browsed, unsent = editor.browse(line, browsed, unsent, key == 16)
+		case '\t':
+			editor.completeWord(line, wasTabbed)
+			tabbed = true
+		case 27:
+			switch editor.escapeSequence() {
+			case "A":
+				browsed, unsent = editor.browse(line, browsed, unsent, true)
+			case "B":
+				browsed, unsent = editor.browse(line, browsed, unsent, false)
+			case "C":
+				line.cursor = min(len(line.text), line.cursor+1)
+			case "D":
+				line.cursor = max(0, line.cursor-1)
+			case "H", "1~", "7~":
+				line.cursor = 0
+			case "F", "4~", "8~":
+				line.cursor = len(line.text)
+			case "3~":
+				line.deleteAt(line.cursor)
+			}
+		default:
+			if key < ' ' {
+				continue
+			}
+			line.text = append(line.text[:line.cursor], append([]rune{key}, line.text[line.cursor:]...)...)
+			line.cursor++
+		}
+		line.redraw(editor.out)
+	}
+}
+
+func (line *editedLine) deleteAt(i int) {
+	if i < len(line.text) {
+		line.text = append(line.text[:i], line.text[i+1:]...)
+	}
+}
+
+// escapeSequence reads the rest of a key sent as ESC [ or ESC O followed by digits and a final letter or ~
+func (editor *lineEditor) escapeSequence() string {
+	if next, _, err := editor.in.ReadRune(); err != nil || (next != '[' && next != 'O') {
+		return ""
+	}
+	var sequence strings.Builder
+	for {
+		key, _, err := editor.in.ReadRune()
+		if err != nil {
+			return ""
+		}
+		sequence.WriteRune(key)
+		if key < '0' || key > '9' {
+			return sequence.String()
+		}
+	}
+}
+
+// browse shows the previous or the next history line and returns the new history position
+func (editor *lineEditor) browse(line *editedLine, browsed int, unsent string, previous bool) (int, string) {
+	switch {
+	case previous && browsed > 0:
+		if browsed == len(editor.history) {
+			unsent = string(line.text)
+		}
+		browsed--
+		line.set(editor.history[browsed])
+	case !previous && browsed < len(editor.history):
+		browsed++
+		if browsed == len(editor.history) {
+			line.set(unsent)
+		} else {
+			line.set(editor.history[browsed])
+		}
+	}
+	return browsed, unsent
+}
+
+// completeWord completes the word before the cursor when a single choice fits, extends it to the
+// part all choices share otherwise, and lists the choices when Tab is pressed twice
+func (editor *lineEditor) completeWord(line *editedLine, listChoices bool) {
+	if editor.complete == nil {
+		return
+	}
+	head := string(line.text[:line.cursor])
+	start, choices := editor.complete(head)
+	if len(choices) == 0 {
+		fmt.Fprint(editor.out, "\a")
+		return
+	}
+
+	replacement := choices[0] + " "
+	if len(choices) > 1 {
+		replacement = commonPrefix(choices)
+	}
+	if len(replacement) > len(head)-start {
+		completed := []rune(head[:start] + replacement)
+		line.text = append(completed, line.text[line.cursor:]...)
+		line.cursor = len(completed)
+		return
+	}
+	if listChoices {
+		fmt.Fprintf(editor.out, "\r\n%s\r\n", strings.Join(choices, "  "))
+	} else {
+		fmt.Fprint(editor.out, "\a")
+	}
+}
+
+// commonPrefix returns the longest text every word starts with, never ending inside a rune
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			runes := []rune(prefix)
+			prefix = string(runes[:len(runes)-1])
+		}
+	}
+	return prefix
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+)
+
+// typeKeys runs the line editor on the keys and returns the line it read
+func typeKeys(t *testing.T, editor *lineEditor, keys string) string {
+	t.Helper()
+	editor.in = bufio.NewReader(strings.NewReader(keys))
+	editor.out = io.Discard
+	line, err := editor.readLine("> ")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return line
+}
+
+// TestLineEditor_Editing tests cursor movement, insertion and deletion
+func TestLineEditor_Editing(t *testing.T) {
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"plan a b 3\r", "plan a b 3"},
+		{"plam\x7fn\r", "plan"},
+		{"pan\x1b[D\x1b[Dl\r", "plan"},
+		{"lan\x01p\x05 a\r", "plan a"},
+		{"xplan\x01\x1b[3~\r", "plan"},
+		{"plan abc\x02\x02\x0b\r", "plan a"},
+		{"junk\x15plan\r", "plan"},
+		{"pl\x1bOHx\x1bOF!\r", "xpl!"},
+		{"Väike\x1b[D\x7f\r", "Väie"},
+		{"drop\x03plan\r", "plan"},
+	}
+	for _, test := range tests {
+		if line := typeKeys(t, &lineEditor{}, test.keys); line != test.expected {
+			t.Errorf("Expected %q for %q, got %q", test.expected, test.keys, line)
+		}
+	}
+}
+
+// TestLineEditor_History tests browsing earlier lines with the arrow keys and Ctrl-P, Ctrl-N
+func TestLineEditor_History(t *testing.T) {
+	editor := &lineEditor{history: []string{"stats", "plan a b 3"}}
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"\x1b[A\r", "plan a b 3"},
+		{"\x1b[A\x1b[A\r", "stats"},
+		{"\x1b[A\x1b[A\x1b[A\r", "stats"},
+		{"\x10\x10\x0e\r", "plan a b 3"},
+		{"new\x1b[A\x1b[B\r", "new"},
+		{"\x1b[A\x7f4\r", "plan a b 4"},
+	}
+	for _, test := range tests {
+		if line := typeKeys(t, editor, test.keys); line != test.expected {
+			t.Errorf("Expected %q for %q, got %q", test.expected, test.keys, line)
+		}
+	}
+}
+
+// TestLineEditor_Completion tests that Tab completes a single choice and the shared start of several
+func TestLineEditor_Completion(t *testing.T) {
+	editor := &lineEditor{complete: newShell(replNetwork(), nil).complete}
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"pl\ta\r", "plan a"},
+		{"clo\t\r", "close"},
+		{"clo\t\td\r", "closed"},
+		{"neighbors \"T\t\r", `neighbors "Tallinn-Väike" `},
+		{"plan x\t\r", "plan x"},
+		{"pl a\x01\x06\x06\t\r", "plan  a"},
+	}
+	for _, test := range tests {
+		if line := typeKeys(t, editor, test.keys); line != test.expected {
+			t.Errorf("Expected %q for %q, got %q", test.expected, test.keys, line)
+		}
+	}
+}
+
+// TestLineEditor_EOF tests that Ctrl-D ends the input only on an empty line
+func TestLineEditor_EOF(t *testing.T) {
+	editor := &lineEditor{in: bufio.NewReader(strings.NewReader("\x04")), out: io.Discard}
+	if _, err := editor.readLine("> "); err != io.EOF {
+		t.Fatalf("Expected io.EOF, got: %v", err)
+	}
+	if line := typeKeys(t, editor, "plann\x02\x04\r"); line != "plan" {
+		t.Fatalf("Expected Ctrl-D to delete under the cursor, got %q", line)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// lintWarning is a style or structure problem found in a map file
+type lintWarning struct {
+	file    string
+	line    int
+	message string
+}
+
+// mapLine is a station or connection together with the file and line it is written on
+type mapLine struct {
+	file string
+	line int
+	from string
+	to   string
+}
+
+// LintNetworkMap loads the map and reports isolated stations, dead-end branches,
+// disconnected clusters and connections that are not listed in canonical order
+func LintNetworkMap(filename string, options LoadOptions) ([]lintWarning, error) {
+	network, err := LoadNetworkMapWithOptions(filename, options)
+	if err != nil {
+		return nil, err
+	}
+	stationLines, connections, err := scanMapLines(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var warnings []lintWarning
+	warn := func(at mapLine, format string, args ...interface{}) {
+		warnings = append(warnings, lintWarning{file: at.file, line: at.line, message: fmt.Sprintf(format, args...)})
+	}
+
+	reported := make(map[string]bool)
+	for _, name := range network.sortedStationNames() {
+		switch len(network.links[name]) {
+		case 0:
+			warn(stationLines[name], "station %s is not connected to any other station", formatName(name))
+		case 1:
+			if reported[name] {
+				continue
+			}
+			branch := network.deadEndBranch(name)
+			last := branch[len(branch)-1]
+			if len(network.links[last]) == 1 {
+				// the whole component is a single line, report it once
+				reported[last] = true
+				warn(stationLines[name], "stations %s form a line with dead ends at both sides", formatRoute(branch))
+			} else {
+				warn(stationLines[name], "station %s is a dead end, branch %s joins the network at %s", formatName(name), formatRoute(branch), formatName(last))
+			}
+		}
+	}
+
+	// every component but the largest is cut off, a map without stations has none
+	for i, component := range network.ConnectedComponents() {
+		if i == 0 || len(component) < 2 {
+			continue // single stations are already reported as isolated
+		}
+		minX, minY, maxX, maxY := network.boundingBox(component)
+		names := make([]string, len(component))
+		for i, name := range component {
+			names[i] = formatName(name)
+		}
+		warn(stationLines[component[0]], "stations %s (coordinates %d,%d to %d,%d) are not connected to the rest of the network",
+			strings.Join(names, ", "), minX, minY, maxX, maxY)
+	}
+
+	// every file is expected to list its own connections in order
+	previousInFile := make(map[string][2]string)
+	for _, connection := range connections {
+		current := linkKey(connection.from, connection.to)
+		previous, seen := previousInFile[connection.file]
+		if seen && (current[0] < previous[0] || (current[0] == previous[0] && current[1] < previous[1])) {
+			warn(connection, "connection %s is listed out of order", formatRoute([]string{connection.from, connection.to}))
+		}
+		previousInFile[connection.file] = current
+	}
+
+	sort.SliceStable(warnings, func(i, j int) bool {
+		if warnings[i].file != warnings[j].file {
+			return warnings[i].file == filename || (warnings[j].file != filename && warnings[i].file < warnings[j].file)
+		}
+		return warnings[i].line < warnings[j].line
+	})
+	return warnings, nil
+}
+
+// deadEndBranch follows the track from a dead-end station until it reaches a junction or another dead end
+func (network *RailNetwork) deadEndBranch(start string) []string {
+	branch := []string{start}
+	previous := ""
+	current := start
+	for {
+		next := ""
+		for neighbor := range network.links[current] {
+			if neighbor != previous {
+				next = neighbor
+				break
+			}
+		}
+		if next == "" || contains(branch, next) {
+			return branch
+		}
+		branch = append(branch, next)
+		if len(network.links[next]) != 2 {
+			return branch
+		}
+		previous, current = current, next
+	}
+}
+
+// boundingBox returns the smallest and largest coordinates used by the given stations
+func (network *RailNetwork) boundingBox(names []string) (int, int, int, int) {
+	first := network.stations[names[0]]
+	minX, minY, maxX, maxY := first.x, first.y, first.x, first.y
+	for _, name := range names[1:] {
+		location := network.stations[name]
+		minX, maxX = min(minX, location.x), max(maxX, location.x)
+		minY, maxY = min(minY, location.y), max(maxY, location.y)
+	}
+	return minX, minY, maxX, maxY
+}
+
+// scanMapLines records where each station and connection of an already validated map is written,
+// following the same include directives as the loader
+func scanMapLines(filename string) (map[string]mapLine, []mapLine, error) {
+	stationLines := make(map[string]mapLine)
+	var connections []mapLine
+	loaded := make(map[string]bool)
+
+	var scanFile func(filename string) error
+	scanFile = func(filename string) error {
+		absolute, err := filepath.Abs(filename)
+		if err != nil {
+			return err
+		}
+		if loaded[absolute] {
+			return nil
+		}
+		loaded[absolute] = true
+
+		file, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		section := ""
+		scanner := bufio.NewScanner(file)
+		for lineNumber := 1; scanner.Scan(); lineNumber++ {
+			line, _, _ := splitComment(scanner.Text())
+			included, isInclude := parseInclude(line)
+			switch {
+			case line == "":
+			case isInclude:
+				if err := scanFile(filepath.Join(filepath.Dir(filename), included)); err != nil {
+					return err
+				}
+			case line == "stations:" || line == "connections:":
+				section = line
+			case section == "stations:":
+				parts, err := splitFields(line, ',')
+				if err != nil {
+					return err
+				}
+				name, err := parseName(parts[0])
+				if err != nil {
+					return err
+				}
+				stationLines[name] = mapLine{file: filename, line: lineNumber, from: name}
+			case section == "connections:":
+				from, to, err := parseLinkNames(line)
+				if err != nil {
+					return err
+				}
+				connections = append(connections, mapLine{file: filename, line: lineNumber, from: from, to: to})
+			}
+		}
+		return scanner.Err()
+	}
+
+	if err := scanFile(filename); err != nil {
+		return nil, nil, err
+	}
+	return stationLines, connections, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestLintNetworkMap tests that every kind of lint warning is reported on the right line
+func TestLintNetworkMap(t *testing.T) {
+	path := filepath.Join(writeMapFiles(t, map[string]string{"test.map": `stations:
+beethoven,1,6
+verdi,7,1
+albinoni,1,1
+part,10,0
+handel,3,14
+mozart,14,9
+bach,20,20
+chopin,21,20
+liszt,30,30
+
+connections:
+beethoven-verdi
+albinoni-beethoven
+verdi-albinoni
+part-verdi
+handel-part
+bach-chopin
+`}), "test.map")
+	warnings, err := LintNetworkMap(path, DefaultLoadOptions)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []lintWarning{
+		{path, 6, "station handel is a dead end, branch handel-part-verdi joins the network at verdi"},
+		{path, 7, "station mozart is not connected to any other station"},
+		{path, 8, "stations bach-chopin form a line with dead ends at both sides"},
+		{path, 8, "stations bach, chopin (coordinates 20,20 to 21,20) are not connected to the rest of the network"},
+		{path, 10, "station liszt is not connected to any other station"},
+		{path, 14, "connection albinoni-beethoven is listed out of order"},
+		{path, 17, "connection handel-part is listed out of order"},
+		{path, 18, "connection bach-chopin is listed out of order"},
+	}
+	if !reflect.DeepEqual(warnings, expected) {
+		t.Fatalf("Expected %v, got %v", expected, warnings)
+	}
+}
+
+// TestLintNetworkMap_Clean tests that a canonical, fully connected map gives no warnings
+func TestLintNetworkMap_Clean(t *testing.T) {
+	path := filepath.Join(writeMapFiles(t, map[string]string{"test.map": `stations:
+a,0,0
+b,0,1
+c,1,1
+
+connections:
+a-b
+a-c
+b-c
+`}), "test.map")
+	warnings, err := LintNetworkMap(path, DefaultLoadOptions)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Fatalf("Expected no warnings, got %v", warnings)
+	}
+}
+
+// TestLintNetworkMap_Empty tests that a map without stations loads and gives no warnings
+func TestLintNetworkMap_Empty(t *testing.T) {
+	path := filepath.Join(writeMapFiles(t, map[string]string{"test.map": "stations:\nconnections:\n"}), "test.map")
+	warnings, err := LintNetworkMap(path, DefaultLoadOptions)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Fatalf("Expected no warnings, got %v", warnings)
+	}
+}
@@ -6,6 +6,8 @@
 // go run . network5.map two four 4
 // go run . network3.map waterloo st_pancras 2
 // go run . network2.map waterloo st_pancras 4
+// go run . network12.map Tallinn-Väike Tartu 4
+// go run . -avoid type=depot network13.map harbour airport 4
 
 // // tests with faulty maps
 // go run . network_err1.map beethoven part 9
@@ -21,43 +23,145 @@
 // go run . network_err10.map beethoven part 9
 // go run . network_err11.map beethoven part 9
 // go run . network_err12.map beethoven part 9
+
+// // formatting and linting maps
+// go run . fmt network1.map
+// go run . lint network7.map
+
+// // k shortest routes between two stations
+// go run . paths -k 5 network7.map small large
+// go run . paths -weight distance -exclude 12 network7.map small large
+// go run . shortest -weight distance network7.map small large
+// go run . -json network7.map small large 9
+// go run . -explain network3.map waterloo st_pancras 2
+// go run . -cache .plans network10.map beginning terminus 20
+// go run . stats network7.map small large
+// go run . shortest -ignore-case network12.map tallinn-väike tartu
+// go run . analyze network7.map small large 9
+// go run . repl network7.map
+// go run . replan -turn 2 -close 12 network7.map small large 9
+// go run . serve -addr localhost:8080 network7.map network3.map
+// go run . bench -timeout 5s
+// go run . generate -topology scale-free -stations 500 -degree 4 -seed 7 -o big.map
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"runtime"
 	"strconv"
+	"strings"
 	"time"
 )
 
 func main() {
 	startTime := time.Now()
-	if len(os.Args) != 5 {
-		log.Fatal("\033[41m ! Error ! \033[0m \nUsage: go run . <network map file> <start station> <end station> <number of trains>")
+	if len(os.Args) > 1 {
+		if command, exists := commands[os.Args[1]]; exists {
+			if err := command(os.Args[2:]); err != nil {
+				log.Fatal("\033[41m ! Error ! \033[0m ", describeError(err))
+			}
+			return
+		}
 	}
-	fileName := os.Args[1]
-	startStation := os.Args[2]
-	endStation := os.Args[3]
-	trainCount, err := strconv.Atoi(os.Args[4])
+	var avoid []string
+	flag.Func("avoid", "never pass through stations with this `key=value` attribute (can be repeated)", func(value string) error {
+		if !strings.Contains(value, "=") {
+			return fmt.Errorf("expected key=value, got %s", value)
+		}
+		avoid = append(avoid, value)
+		return nil
+	})
+	maxStations := flag.Int("max-stations", DefaultLoadOptions.MaxStations, "largest accepted number of stations, 0 for no limit")
+	timeout := flag.Duration("timeout", 0, "stop searching after this long and print the best schedule found, 0 for no limit")
+	maxPathLength := flag.Int("max-path-length", 0, "most stations on one route, 0 for no limit")
+	maxPaths := flag.Int("max-paths", 0, "most routes to consider, 0 for no limit")
+	workers := flag.Int("workers", runtime.NumCPU(), "goroutines used for the search")
+	jsonOutput := flag.Bool("json", false, "print the plan, its lower bound and the schedule as JSON")
+	explain := flag.Bool("explain", false, "also print every candidate route set and why the plan was chosen")
+	ignoreCase := flag.Bool("ignore-case", false, "accept station names that differ only in case")
+	cacheDir := flag.String("cache", "", "directory where plans are kept and looked up before planning")
+	flag.Parse()
+
+	if flag.NArg() != 4 {
+		log.Fatal("\033[41m ! Error ! \033[0m \nUsage: go run . [-avoid key=value] [-max-stations n] [-timeout d] [-max-path-length n] [-max-paths n] [-workers n] [-json] [-explain] [-ignore-case] [-cache dir] <network map file> <start station> <end station> <number of trains>\n       go run . fmt [-w] [-l] <network map file>...\n       go run . lint <network map file>...\n       go run . paths [-k n] [-weight hops|distance] [-exclude station]... [-ignore-case] <network map file> <start station> <end station>\n       go run . analyze [-fast] [-all] [-workers n] [-timeout d] [-ignore-case] <network map file> <start station> <end station> <number of trains>\n       go run . bench [-trains n] [-workers n] [-timeout d]\n       go run . generate [-stations n] [-degree d] [-topology t] [-seed s] [-o file]\n       go run . stats [-ignore-case] <network map file> [<start station> <end station>]\n       go run . shortest [-weight hops|distance] [-ignore-case] <network map file> <start station> <end station>\n       go run . repl [-ignore-case] [-timeout d] [-history file] <network map file>\n       go run . replan [-turn n] [-schedule file] [-close station]... [-close-link a-b]... [-ignore-case] <network map file> <start station> <end station> <number of trains>\n       go run . serve [-addr host:port] [-timeout d] [-workers n] [-watch d] [-cache-size n] [-cache dir] [<network map file>...]")
+	}
+	fileName := flag.Arg(0)
+	startStation := flag.Arg(1)
+	endStation := flag.Arg(2)
+	trainCount, err := strconv.Atoi(flag.Arg(3))
 	if err != nil || trainCount <= 0 {
 		log.Fatal("\033[41m ! Error ! \033[0m Number of trains must be a valid positive integer")
 	}
 
-	network, err := LoadNetworkMap(fileName)
+	network, err := LoadNetworkMapWithOptions(fileName, LoadOptions{MaxStations: *maxStations})
 	if err != nil {
 		log.Fatal("\033[41m ! Error ! \033[0m Error loading network map:", err)
 	}
+	if *ignoreCase {
+		startStation, endStation, err = network.ResolveEndpoints(startStation, endStation)
+		if err != nil {
+			log.Fatal("\033[41m ! Error ! \033[0m ", describeError(err))
+		}
+	}
 
-	routes, err := network.ExplorePaths(startStation, endStation)
-	if err != nil {
-		log.Fatal("\033[41m ! Error ! \033[0m Error exploring paths:", err)
+	// avoided stations may still be where the trains start or end
+	var closed []string
+	for _, attribute := range avoid {
+		key, value, _ := strings.Cut(attribute, "=")
+		for _, name := range network.StationsWithAttribute(key, value) {
+			if name != startStation && name != endStation {
+				closed = append(closed, name)
+			}
+		}
 	}
+	network = network.Without(closed...)
 
-	validRoutes := ValidateRoutes(routes)
-	optimalCombos := SelectOptimalCombos(validRoutes)
-	bestPlan := AllocateTrains(trainCount, optimalCombos)
-	DisplaySchedule(bestPlan, trainCount)
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	options := PlanOptions{
+		ExploreOptions: ExploreOptions{MaxPathLength: *maxPathLength, MaxPaths: *maxPaths, Workers: *workers},
+		Explain:        *explain,
+	}
+	var cache *PlanCache
+	if *cacheDir != "" {
+		if cache, err = NewPlanCache(*cacheDir, 0); err != nil {
+			log.Fatal("\033[41m ! Error ! \033[0m ", err)
+		}
+	}
+	result, cached, err := cache.Plan(ctx, network, startStation, endStation, trainCount, options)
+	if err != nil {
+		log.Fatal("\033[41m ! Error ! \033[0m Error exploring paths:", describeError(err))
+	}
+	if *jsonOutput {
+		if err := WritePlanJSON(os.Stdout, result, startStation, endStation, trainCount); err != nil {
+			log.Fatal("\033[41m ! Error ! \033[0m ", err)
+		}
+	} else {
+		DisplaySchedule(result.Plan, trainCount)
+		if *explain {
+			fmt.Println()
+			writeExplanation(os.Stdout, explainPlan(result))
+		}
+	}
+	if result.Suboptimal {
+		fmt.Fprintln(os.Stderr, "\n\033[43m ! Warning ! \033[0m The search was stopped early, the schedule may not be optimal")
+	}
+	fmt.Fprintf(os.Stderr, "\n\033[100m Turns: \033[0m %d, lower bound %d\n", result.Plan.totalTurns, result.LowerBound)
+	if cached {
+		fmt.Fprintf(os.Stderr, "The plan was found in the cache in %s\n", *cacheDir)
+	}
+	if result.Gap() > 0 {
+		fmt.Fprintf(os.Stderr, "\033[43m ! Warning ! \033[0m The schedule takes %d turns more than the lower bound, a shorter one may exist\n", result.Gap())
+	}
 
 	// those two lines (just different versions) are the ones that cause the number to be bigger when you add "wc -l" in the terminal
 	// fmt.Println("\nProgram executed in: ", time.Since(startTime))
@@ -67,3 +171,17 @@ func main() {
 	// fmt.Fprintln(os.Stderr, "\nProgram executed in: ", time.Since(startTime))
 	fmt.Fprintln(os.Stderr, "\n\033[100m Program executed in: \033[0m", time.Since(startTime))
 }
+
+// describeError adds the diagnosis to an error about stations that no route joins
+// and the closest names to an error about a station that does not exist
+func describeError(err error) string {
+	var noRoute *NoRouteError
+	if errors.As(err, &noRoute) {
+		return err.Error() + "\n" + noRoute.Diagnosis()
+	}
+	var unknown *UnknownStationError
+	if errors.As(err, &unknown) && len(unknown.Suggestions) > 0 {
+		return err.Error() + ", " + unknown.Hint()
+	}
+	return err.Error()
+}
@@ -2,60 +2,156 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
 
-// LoadNetworkMap reads and constructs the railway network from the file
+// LoadOptions configures how map files are loaded
+type LoadOptions struct {
+	// MaxStations is the largest number of stations accepted, 0 means no limit
+	MaxStations int
+}
+
+// DefaultLoadOptions are the options used by LoadNetworkMap
+var DefaultLoadOptions = LoadOptions{MaxStations: 10000}
+
+// mapLoader holds the state shared by a map file and all the files it includes
+type mapLoader struct {
+	options       LoadOptions
+	network       *RailNetwork
+	stationsCount int
+	coordinates   map[string]string // "x,y" -> position of the station that uses them
+	stations      map[string]string // station name -> position where it is defined
+	links         map[[2]string]bool
+	loaded        map[string]bool // files already merged into the network
+	files         []string        // the files opened so far in the order they were read
+	includeStack  []string        // files currently being read, outermost first
+	noIncludes    bool            // the map did not come from a file, so there is nothing to include relative to
+	pending       []string        // comment lines waiting for the next station or connection
+	itemsSeen     bool
+}
+
+// LoadNetworkMap reads and constructs the railway network from the file,
+// following include "other.map" directives relative to the including file
 func LoadNetworkMap(filename string) (*RailNetwork, error) {
-	file, err := os.Open(filename)
-	if err != nil {
+	return LoadNetworkMapWithOptions(filename, DefaultLoadOptions)
+}
+
+// LoadNetworkMapWithOptions is LoadNetworkMap with a configurable station limit
+func LoadNetworkMapWithOptions(filename string, options LoadOptions) (*RailNetwork, error) {
+	network, _, err := loadNetworkMapFiles(filename, options)
+	return network, err
+}
+
+// loadNetworkMapFiles is LoadNetworkMapWithOptions that also returns the files it read, the given
+// one first and then the included ones. When loading fails they are the files read until the error
+func loadNetworkMapFiles(filename string, options LoadOptions) (*RailNetwork, []string, error) {
+	loader := newMapLoader(options)
+	if err := loader.loadFile(filename); err != nil {
+		return nil, loader.files, err
+	}
+	return loader.finish(), loader.files, nil
+}
+
+// LoadNetworkMapData reads a network from map text held in memory, such as an uploaded map.
+// The name is used in error positions, include directives are not allowed
+func LoadNetworkMapData(name string, data []byte, options LoadOptions) (*RailNetwork, error) {
+	loader := newMapLoader(options)
+	loader.noIncludes = true
+	loader.includeStack = []string{name}
+	if err := loader.loadData(name, data); err != nil {
 		return nil, err
 	}
-	defer file.Close()
+	return loader.finish(), nil
+}
+
+func newMapLoader(options LoadOptions) *mapLoader {
+	return &mapLoader{
+		options:     options,
+		network:     NewRailNetwork(),
+		coordinates: make(map[string]string),
+		stations:    make(map[string]string),
+		links:       make(map[[2]string]bool),
+		loaded:      make(map[string]bool),
+	}
+}
 
-	scanner := bufio.NewScanner(file)
-	network := NewRailNetwork()
+// finish returns the loaded network with the comments after the last item as its footer
+func (loader *mapLoader) finish() *RailNetwork {
+	loader.network.footer = loader.pending
 
-	isEmpty, stationsSectionFound, connectionsSectionFound := checkSections(scanner)
-	if isEmpty {
-		return nil, fmt.Errorf("file is empty")
+	// the planner works on the compact graph, build it once while the network is still private
+	loader.network.Compact()
+	return loader.network
+}
+
+// loadFile merges one map file into the network, recursing into its includes
+func (loader *mapLoader) loadFile(filename string) error {
+	absolute, err := filepath.Abs(filename)
+	if err != nil {
+		return err
 	}
-	if !stationsSectionFound {
-		return nil, fmt.Errorf("'stations:' section does not exist")
+	for i, open := range loader.includeStack {
+		if open == absolute {
+			var cycle []string
+			for _, path := range append(loader.includeStack[i:], absolute) {
+				cycle = append(cycle, filepath.Base(path))
+			}
+			return fmt.Errorf("include cycle: %s", strings.Join(cycle, " -> "))
+		}
 	}
-	if !connectionsSectionFound {
-		return nil, fmt.Errorf("'connections:' section does not exist")
+	if loader.loaded[absolute] {
+		return nil // every file is merged only once, even when several files include it
 	}
+	loader.loaded[absolute] = true
+	loader.includeStack = append(loader.includeStack, absolute)
+	defer func() { loader.includeStack = loader.includeStack[:len(loader.includeStack)-1] }()
 
-	// If the sections exist, we do a second pass to process the contents of the file
-	file.Seek(0, 0)
-	scanner = bufio.NewScanner(file)
-
-	stationsCount, err := processStationsAndConnections(scanner, network)
+	loader.files = append(loader.files, filename)
+	data, err := os.ReadFile(filename)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	return loader.loadData(filename, data)
+}
+
+// loadData merges the contents of one map file into the network
+func (loader *mapLoader) loadData(filename string, data []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	isEmpty, stationsSectionFound, connectionsSectionFound, includeFound := checkSections(scanner)
+	if isEmpty {
+		return fmt.Errorf("file is empty")
 	}
-	if stationsCount > 10000 {
-		return nil, fmt.Errorf("map contains more than 10000 stations")
+	// a file that only includes other files does not need sections of its own
+	if !stationsSectionFound && !includeFound {
+		return fmt.Errorf("'stations:' section does not exist")
 	}
-	if err := scanner.Err(); err != nil {
-		return nil, err
+	if !connectionsSectionFound && !includeFound {
+		return fmt.Errorf("'connections:' section does not exist")
+	}
+
+	// If the sections exist, we do a second pass to process the contents of the file
+	scanner = bufio.NewScanner(bytes.NewReader(data))
+
+	if err := loader.processStationsAndConnections(scanner, filename); err != nil {
+		return err
 	}
-	return network, nil
+	return scanner.Err()
 }
 
-func checkSections(scanner *bufio.Scanner) (bool, bool, bool) {
+func checkSections(scanner *bufio.Scanner) (bool, bool, bool, bool) {
 	isEmpty := true
 	stationsSectionFound := false
 	connectionsSectionFound := false
+	includeFound := false
 
 	for scanner.Scan() {
-		line := scanner.Text()
-		line = strings.Split(line, "#")[0]
-		line = strings.TrimSpace(line)
+		line, _, _ := splitComment(scanner.Text())
 		if line == "" {
 			continue
 		}
@@ -65,104 +161,204 @@ func checkSections(scanner *bufio.Scanner) (bool, bool, bool) {
 			stationsSectionFound = true
 		} else if line == "connections:" {
 			connectionsSectionFound = true
+		} else if _, isInclude := parseInclude(line); isInclude {
+			includeFound = true
 		}
 	}
 
-	return isEmpty, stationsSectionFound, connectionsSectionFound
+	return isEmpty, stationsSectionFound, connectionsSectionFound, includeFound
 }
 
-func processStationsAndConnections(scanner *bufio.Scanner, network *RailNetwork) (int, error) {
+func (loader *mapLoader) processStationsAndConnections(scanner *bufio.Scanner, filename string) error {
 	readStations := false
 	readLinks := false
+	network := loader.network
+	nested := len(loader.includeStack) > 1
 
-	stationsCount := 0
-	coordinates := make(map[string]string)
-	stations := make(map[string]string)
-	links := make(map[string]bool)
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		line = strings.Split(line, "#")[0]
-		line = strings.TrimSpace(line)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line, comment, hasComment := splitComment(scanner.Text())
+		if hasComment {
+			loader.pending = append(loader.pending, comment)
+		}
 		if line == "" {
 			continue
 		}
+		position := fmt.Sprintf("%s:%d", filename, lineNumber)
 
-		if line == "stations:" {
-			readStations = true
-			readLinks = false
+		if included, isInclude := parseInclude(line); isInclude {
+			if loader.noIncludes {
+				return fmt.Errorf("%s: include %q is only allowed in map files", position, included)
+			}
+			path := filepath.Join(filepath.Dir(filename), included)
+			if err := loader.loadFile(path); err != nil {
+				return fmt.Errorf("%s: %v", position, err)
+			}
 			continue
 		}
-		if line == "connections:" {
-			readStations = false
-			readLinks = true
+
+		if line == "stations:" || line == "connections:" {
+			// comments above the first section describe the whole map
+			if !loader.itemsSeen && !nested && !readStations && !readLinks {
+				network.header = append(network.header, loader.pending...)
+				loader.pending = nil
+			}
+			readStations = line == "stations:"
+			readLinks = line == "connections:"
 			continue
 		}
 
+		var err error
 		if readStations {
-			if err := processStation(line, stations, coordinates, network); err != nil {
-				return 0, err
-			}
-			stationsCount++
+			err = loader.processStation(line, position)
 		} else if readLinks {
-			if err := processLink(line, links, network); err != nil {
-				return 0, err
+			err = loader.processLink(line)
+		}
+		if err != nil {
+			// errors inside included files tell where they happened
+			if nested {
+				return fmt.Errorf("%s: %v", position, err)
 			}
+			return err
 		}
 	}
 
-	return stationsCount, nil
+	// comments after the last item of an included file end with it, the including file goes on with its own
+	if nested {
+		loader.pending = nil
+	}
+	return nil
+}
+
+// parseInclude recognizes an include "file.map" directive and returns the file name
+func parseInclude(line string) (string, bool) {
+	rest, found := strings.CutPrefix(line, "include ")
+	if !found {
+		return "", false
+	}
+	name, err := strconv.Unquote(strings.TrimSpace(rest))
+	if err != nil {
+		return "", false
+	}
+	return name, true
 }
 
-func processStation(line string, stations, coordinates map[string]string, network *RailNetwork) error {
-	parts := strings.Split(line, ",")
+func (loader *mapLoader) processStation(line, position string) error {
+	network := loader.network
+	parts, err := splitFields(line, ',')
+	if err != nil {
+		return err
+	}
 	if len(parts) != 3 {
 		return fmt.Errorf("station %s does not have correct amount of coordinates", parts[0])
 	}
-	name := strings.TrimSpace(parts[0])
+	name, err := parseName(parts[0])
+	if err != nil {
+		return err
+	}
 
-	xCoord := strings.TrimSpace(parts[1])
-	yCoord := strings.TrimSpace(parts[2])
+	// the last field holds the y coordinate followed by optional key=value attributes
+	attributeFields, err := splitBlanks(parts[2])
+	if err != nil {
+		return err
+	}
+	xCoord := parts[1]
+	yCoord := attributeFields[0]
+	attributes := make(map[string]string)
+	for _, field := range attributeFields[1:] {
+		if field == "" {
+			continue
+		}
+		key, value, found := strings.Cut(field, "=")
+		if !found || !validAttributeKey(key) {
+			return fmt.Errorf("station %s has invalid attribute %s", name, field)
+		}
+		if _, exists := attributes[key]; exists {
+			return fmt.Errorf("station %s has attribute %s twice", name, key)
+		}
+		if value, err = parseName(value); err != nil {
+			return err
+		}
+		attributes[key] = value
+	}
 	// Check if the coordinates are numeric and not negative
-	if x, err := strconv.Atoi(xCoord); err != nil || x < 0 {
+	x, err := strconv.Atoi(xCoord)
+	if err != nil || x < 0 {
 		return fmt.Errorf("station %s has invalid coordinate %s", name, xCoord)
 	}
-	if y, err := strconv.Atoi(yCoord); err != nil || y < 0 {
+	y, err := strconv.Atoi(yCoord)
+	if err != nil || y < 0 {
 		return fmt.Errorf("station %s has invalid coordinate %s", name, yCoord)
 	}
 
+	// Stop reading as soon as the map is known to be too big
+	if loader.options.MaxStations > 0 && loader.stationsCount >= loader.options.MaxStations {
+		return fmt.Errorf("map contains more than %d stations", loader.options.MaxStations)
+	}
+
 	// Check if the station name is unique
-	if _, exists := stations[name]; exists {
+	if defined, exists := loader.stations[name]; exists {
+		if !sameFile(defined, position) {
+			return loader.crossFileError(position, "station %s is already defined at %s", name, defined)
+		}
 		return fmt.Errorf("station list has two stations with same name: %s", name)
 	}
-	stations[name] = line
+	loader.stations[name] = position
 
 	// Check if coordinates are unique
-	coord := fmt.Sprintf("%s,%s", xCoord, yCoord)
-	if _, exists := coordinates[coord]; exists {
+	coord := fmt.Sprintf("%d,%d", x, y)
+	if other, exists := loader.coordinates[coord]; exists {
+		if !sameFile(other, position) {
+			return loader.crossFileError(position, "station %s has the same coordinates as the station at %s", name, other)
+		}
 		return fmt.Errorf("two or more stations have same coordinates")
 	}
-	coordinates[coord] = name
+	loader.coordinates[coord] = position
 
 	network.AddLocation(name)
-	return nil
+	for key, value := range attributes {
+		network.SetAttribute(name, key, value)
+	}
+	network.stations[name].comments = loader.pending
+	loader.pending = nil
+	loader.itemsSeen = true
+	loader.stationsCount++
+	return network.SetCoordinates(name, x, y)
 }
 
-func processLink(line string, links map[string]bool, network *RailNetwork) error {
-	parts := strings.Split(line, "-")
-	if len(parts) != 2 {
-		return fmt.Errorf("connections section has fault in '%s' row: incorrect amount of stations in row", parts[0])
+// crossFileError is an error about a conflict with another file, which names the lines in both files.
+// Errors inside included files are given their position by processStationsAndConnections, the
+// top-level file gets it here
+func (loader *mapLoader) crossFileError(position, format string, args ...any) error {
+	err := fmt.Errorf(format, args...)
+	if len(loader.includeStack) == 1 {
+		return fmt.Errorf("%s: %v", position, err)
+	}
+	return err
+}
+
+func (loader *mapLoader) processLink(line string) error {
+	from, to, err := parseLinkNames(line)
+	if err != nil {
+		return err
 	}
-	from := strings.TrimSpace(parts[0])
-	to := strings.TrimSpace(parts[1])
 	// check for duplicate connections
-	linkKey1 := fmt.Sprintf("%s-%s", from, to)
-	linkKey2 := fmt.Sprintf("%s-%s", to, from)
-	if links[linkKey1] || links[linkKey2] {
+	if loader.links[linkKey(from, to)] {
 		return fmt.Errorf("duplicate connection between %s and %s", from, to)
 	}
-	links[linkKey1] = true
-	links[linkKey2] = true
+	loader.links[linkKey(from, to)] = true
+
+	if err := loader.network.AddLink(from, to); err != nil {
+		return err
+	}
+	if len(loader.pending) > 0 {
+		loader.network.linkComments[linkKey(from, to)] = loader.pending
+	}
+	loader.pending = nil
+	loader.itemsSeen = true
+	return nil
+}
 
-	return network.AddLink(from, to)
+// sameFile reports whether two "file:line" positions point into the same file
+func sameFile(a, b string) bool {
+	return a[:strings.LastIndex(a, ":")] == b[:strings.LastIndex(b, ":")]
 }
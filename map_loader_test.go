@@ -1,6 +1,12 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -135,3 +141,261 @@ func TestLoadNetworkMap_InvalidConnectionRow(t *testing.T) {
 		t.Fatalf("Test didn't pass. Expected 'connections section has fault in 'handel' row: incorrect amount of stations in row' error, got: %v", err)
 	}
 }
+
+// writeMapFiles writes the named map files into a temporary directory and returns its path
+func writeMapFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// testing that included regional files are merged into one network
+func TestLoadNetworkMap_Include(t *testing.T) {
+	dir := writeMapFiles(t, map[string]string{
+		"national.map": `include "regions/north.map"
+include "regions/south.map"
+
+connections:
+tallinn-tartu
+`,
+		"regions/north.map": `stations:
+tallinn,0,0
+rakvere,5,1
+
+connections:
+tallinn-rakvere
+`,
+		"regions/south.map": `include "north.map" # already merged, not read again
+stations:
+tartu,4,8
+valga,3,12
+
+connections:
+tartu-valga
+rakvere-tartu
+`,
+	})
+	network, err := LoadNetworkMap(filepath.Join(dir, "national.map"))
+	if err != nil {
+		t.Fatalf("Test didn't pass. Unexpected error: %v", err)
+	}
+	expected := []string{"rakvere", "tallinn", "tartu", "valga"}
+	if names := network.sortedStationNames(); !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Test didn't pass. Expected stations %v, got %v", expected, names)
+	}
+	for _, link := range [][2]string{{"tallinn", "rakvere"}, {"tartu", "valga"}, {"rakvere", "tartu"}, {"tallinn", "tartu"}} {
+		if !network.links[link[0]][link[1]] {
+			t.Fatalf("Test didn't pass. Expected connection %s-%s", link[0], link[1])
+		}
+	}
+}
+
+// testing that comments at the end of an included file are not moved onto the next station of the including file
+func TestLoadNetworkMap_IncludeTrailingComments(t *testing.T) {
+	dir := writeMapFiles(t, map[string]string{
+		"main.map":  "include \"north.map\"\nstations:\n# south\ntartu,4,8\n\nconnections:\ntallinn-tartu\n",
+		"north.map": "stations:\ntallinn,0,0\n\nconnections:\n# end of north\n",
+	})
+	network, err := LoadNetworkMap(filepath.Join(dir, "main.map"))
+	if err != nil {
+		t.Fatalf("Test didn't pass. Unexpected error: %v", err)
+	}
+	var written strings.Builder
+	if err := network.WriteNetworkMap(&written); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(written.String(), "end of north") || !strings.Contains(written.String(), "# south\ntartu") {
+		t.Fatalf("Test didn't pass. Expected only the comment of tartu above it, got:\n%s", written.String())
+	}
+}
+
+// testing that stations defined twice in different files report both positions
+func TestLoadNetworkMap_IncludeDuplicateStation(t *testing.T) {
+	dir := writeMapFiles(t, map[string]string{
+		"national.map": "include \"north.map\"\ninclude \"south.map\"\n",
+		"north.map":    "stations:\ntallinn,0,0\n\nconnections:\n",
+		"south.map":    "stations:\ntartu,4,8\ntallinn,1,1\n\nconnections:\n",
+	})
+	_, err := LoadNetworkMap(filepath.Join(dir, "national.map"))
+	expected := fmt.Sprintf("%s:2: %s:3: station tallinn is already defined at %s:2",
+		filepath.Join(dir, "national.map"), filepath.Join(dir, "south.map"), filepath.Join(dir, "north.map"))
+	if err == nil || err.Error() != expected {
+		t.Fatalf("Test didn't pass. Expected '%s' error, got: %v", expected, err)
+	}
+}
+
+// testing that stations in different files sharing coordinates report both positions
+func TestLoadNetworkMap_IncludeDuplicateCoordinates(t *testing.T) {
+	dir := writeMapFiles(t, map[string]string{
+		"national.map": "include \"north.map\"\nstations:\ntartu,0,0\n\nconnections:\n",
+		"north.map":    "stations:\ntallinn,0,0\n\nconnections:\n",
+	})
+	_, err := LoadNetworkMap(filepath.Join(dir, "national.map"))
+	expected := fmt.Sprintf("%s:3: station tartu has the same coordinates as the station at %s:2",
+		filepath.Join(dir, "national.map"), filepath.Join(dir, "north.map"))
+	if err == nil || err.Error() != expected {
+		t.Fatalf("Test didn't pass. Expected '%s' error, got: %v", expected, err)
+	}
+}
+
+// testing that a station of the including file defined again after an include names both lines
+func TestLoadNetworkMap_IncludeRedefinedStation(t *testing.T) {
+	dir := writeMapFiles(t, map[string]string{
+		"national.map": "include \"north.map\"\nstations:\ntallinn,1,1\n\nconnections:\n",
+		"north.map":    "stations:\ntallinn,0,0\n\nconnections:\n",
+	})
+	_, err := LoadNetworkMap(filepath.Join(dir, "national.map"))
+	expected := fmt.Sprintf("%s:3: station tallinn is already defined at %s:2",
+		filepath.Join(dir, "national.map"), filepath.Join(dir, "north.map"))
+	if err == nil || err.Error() != expected {
+		t.Fatalf("Test didn't pass. Expected '%s' error, got: %v", expected, err)
+	}
+}
+
+// testing that files including each other are rejected
+func TestLoadNetworkMap_IncludeCycle(t *testing.T) {
+	dir := writeMapFiles(t, map[string]string{
+		"a.map": "include \"b.map\"\n",
+		"b.map": "include \"c.map\"\n",
+		"c.map": "include \"a.map\"\n",
+	})
+	_, err := LoadNetworkMap(filepath.Join(dir, "a.map"))
+	if err == nil || !strings.HasSuffix(err.Error(), "include cycle: a.map -> b.map -> c.map -> a.map") {
+		t.Fatalf("Test didn't pass. Expected include cycle error, got: %v", err)
+	}
+}
+
+// testing key=value attributes after the station coordinates
+func TestLoadNetworkMap_StationAttributes(t *testing.T) {
+	network, err := LoadNetworkMap("network13.map")
+	if err != nil {
+		t.Fatalf("Test didn't pass. Unexpected error: %v", err)
+	}
+	if value, found := network.Attribute("airport", "platforms"); !found || value != "2" {
+		t.Fatalf("Test didn't pass. Expected airport platforms=2, got %q, %v", value, found)
+	}
+	if value, found := network.Attribute("old_town", "name"); !found || value != "Old Town" {
+		t.Fatalf("Test didn't pass. Expected quoted attribute value 'Old Town', got %q, %v", value, found)
+	}
+	if _, found := network.Attribute("market", "type"); found {
+		t.Fatalf("Test didn't pass. Expected market to have no type attribute")
+	}
+	expected := []string{"museum", "old_town"}
+	if zoned := network.StationsWithAttribute("zone", "2"); !reflect.DeepEqual(zoned, expected) {
+		t.Fatalf("Test didn't pass. Expected %v in zone 2, got %v", expected, zoned)
+	}
+}
+
+// testing attributes that are not written as key=value or are given twice
+func TestLoadNetworkMap_InvalidStationAttributes(t *testing.T) {
+	tests := map[string]string{
+		"depot,4,0 depot":                       "station depot has invalid attribute depot",
+		"depot,4,0 =depot":                      "station depot has invalid attribute =depot",
+		`depot,4,0 "="`:                         `station depot has invalid attribute "="`,
+		"depot,4,0 type=depot zone=1 type=yard": "station depot has attribute type twice",
+		"depot,4,0\tdepot":                      "station depot has invalid attribute depot",
+	}
+	for line, expected := range tests {
+		path := filepath.Join(writeMapFiles(t, map[string]string{"test.map": "stations:\n" + line + "\n\nconnections:\n"}), "test.map")
+		_, err := LoadNetworkMap(path)
+		if err == nil || err.Error() != expected {
+			t.Fatalf("Test didn't pass. Expected '%s' error, got: %v", expected, err)
+		}
+	}
+}
+
+// TestLoadNetworkMapData tests that map text in memory loads like the same file, without includes
+func TestLoadNetworkMapData(t *testing.T) {
+	for _, file := range []string{"network7.map", "network12.map", "network13.map"} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fromFile, err := LoadNetworkMap(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fromData, err := LoadNetworkMapData(file, data, DefaultLoadOptions)
+		if err != nil {
+			t.Fatalf("Failed to load %s from memory: %v", file, err)
+		}
+		var expected, written bytes.Buffer
+		fromFile.WriteNetworkMap(&expected)
+		fromData.WriteNetworkMap(&written)
+		if !bytes.Equal(expected.Bytes(), written.Bytes()) {
+			t.Errorf("Expected %s to load the same from memory, got:\n%s", file, written.String())
+		}
+	}
+
+	_, err := LoadNetworkMapData("upload", []byte("stations:\na,0,0\nconnections:\ninclude \"network3.map\"\n"), DefaultLoadOptions)
+	if err == nil || err.Error() != `upload:4: include "network3.map" is only allowed in map files` {
+		t.Fatalf("Expected an include error, got: %v", err)
+	}
+}
+
+// BenchmarkLoadNetworkMap times loading the generated benchmark maps
+func BenchmarkLoadNetworkMap(b *testing.B) {
+	dir := b.TempDir()
+	for _, bench := range benchCases {
+		filename, err := bench.writeMap(dir)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := LoadNetworkMapWithOptions(filename, LoadOptions{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// FuzzLoadNetworkMap tests that the loader never panics and returns either a network or an error,
+// and that every network it accepts is written back into a map that loads the same way
+func FuzzLoadNetworkMap(f *testing.F) {
+	files, err := filepath.Glob("network*.map")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, file := range files {
+		contents, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(contents))
+	}
+	f.Add("stations:\n\"a,b\",1,2 zone=\"x y\"\nc,3,4\nconnections:\n\"a,b\"-c # comment\n")
+
+	f.Fuzz(func(t *testing.T, contents string) {
+		path := filepath.Join(t.TempDir(), "fuzz.map")
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		network, err := LoadNetworkMapWithOptions(path, LoadOptions{MaxStations: 1000})
+		if (network == nil) == (err == nil) {
+			t.Fatalf("expected either a network or an error, got %v and %v", network, err)
+		}
+		if err != nil {
+			return
+		}
+		reloaded, written := writeAndReload(t, network)
+		var again bytes.Buffer
+		if err := reloaded.WriteNetworkMap(&again); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(written, again.Bytes()) {
+			t.Fatalf("map changed after a round trip:\n%s\n%s", written, again.Bytes())
+		}
+	})
+}
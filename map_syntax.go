@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Station names may be written in double quotes using Go string syntax, e.g. "Tallinn-Väike".
+// Inside quotes the separators ',' and '-', the comment sign '#' and spaces are part of the name.
+
+// splitComment separates a map line into its trimmed content and the text of a trailing '#' comment
+func splitComment(raw string) (string, string, bool) {
+	inQuotes := false
+	for i := 0; i < len(raw); i++ {
+		switch raw[i] {
+		case '\\':
+			if inQuotes {
+				i++
+			}
+		case '"':
+			inQuotes = !inQuotes
+		case '#':
+			if !inQuotes {
+				return strings.TrimSpace(raw[:i]), strings.TrimSpace(raw[i+1:]), true
+			}
+		}
+	}
+	return strings.TrimSpace(raw), "", false
+}
+
+// splitFields splits a map line on sep, leaving separators inside quoted names untouched
+func splitFields(line string, sep byte) ([]string, error) {
+	return splitFieldsFunc(line, func(c byte) bool { return c == sep })
+}
+
+// splitBlanks splits a map line on spaces and tabs outside quoted names. Runs of blanks give empty fields
+func splitBlanks(line string) ([]string, error) {
+	return splitFieldsFunc(line, func(c byte) bool { return c == ' ' || c == '\t' })
+}
+
+// splitFieldsFunc splits a map line on the bytes isSep accepts outside quoted names
+func splitFieldsFunc(line string, isSep func(byte) bool) ([]string, error) {
+	var fields []string
+	inQuotes := false
+	start := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			if inQuotes {
+				i++
+			}
+		case '"':
+			inQuotes = !inQuotes
+		default:
+			if !inQuotes && isSep(line[i]) {
+				fields = append(fields, strings.TrimSpace(line[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("unterminated quote in row '%s'", line)
+	}
+	return append(fields, strings.TrimSpace(line[start:])), nil
+}
+
+// parseName returns the station name written in a field, unquoting it when needed
+func parseName(field string) (string, error) {
+	if !strings.HasPrefix(field, `"`) {
+		return field, nil
+	}
+	name, err := strconv.Unquote(field)
+	if err != nil {
+		return "", fmt.Errorf("invalid quoted station name %s", field)
+	}
+	return name, nil
+}
+
+// parseLinkNames returns the two station names of a connection row
+func parseLinkNames(line string) (string, string, error) {
+	parts, err := splitFields(line, '-')
+	if err != nil {
+		return "", "", err
+	}
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("connections section has fault in '%s' row: incorrect amount of stations in row", parts[0])
+	}
+	from, err := parseName(parts[0])
+	if err != nil {
+		return "", "", err
+	}
+	to, err := parseName(parts[1])
+	if err != nil {
+		return "", "", err
+	}
+	return from, to, nil
+}
+
+// formatName writes a station name so that the map parser reads it back unchanged,
+// quoting only the names that would otherwise be split or cut short
+func formatName(name string) string {
+	needsQuotes := name == "" || strings.ContainsAny(name, `,-#"\`) ||
+		strings.IndexFunc(name, func(r rune) bool {
+			return unicode.IsSpace(r) || !unicode.IsPrint(r)
+		}) >= 0
+	if needsQuotes {
+		return strconv.Quote(name)
+	}
+	return name
+}
+
+// validAttributeKey reports whether an attribute key can be written without quotes,
+// keys are made of letters, digits and underscores
+func validAttributeKey(key string) bool {
+	return key != "" && strings.IndexFunc(key, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}) < 0
+}
+
+// formatRoute joins station names with '-' the way connection rows are written
+func formatRoute(names []string) string {
+	formatted := make([]string, len(names))
+	for i, name := range names {
+		formatted[i] = formatName(name)
+	}
+	return strings.Join(formatted, "-")
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestSplitComment tests that '#' inside a quoted name does not start a comment
+func TestSplitComment(t *testing.T) {
+	tests := []struct {
+		raw, content, comment string
+		found                 bool
+	}{
+		{"waterloo,3,1", "waterloo,3,1", "", false},
+		{" st_pancras,5,15 # international", "st_pancras,5,15", "international", true},
+		{`"platform #9",1,2 # quoted`, `"platform #9",1,2`, "quoted", true},
+		{`"say \"#\"",1,2`, `"say \"#\"",1,2`, "", false},
+	}
+	for _, tt := range tests {
+		content, comment, found := splitComment(tt.raw)
+		if content != tt.content || comment != tt.comment || found != tt.found {
+			t.Errorf("splitComment(%q) = %q, %q, %v", tt.raw, content, comment, found)
+		}
+	}
+}
+
+// TestParseLinkNames tests connection rows with plain and quoted station names
+func TestParseLinkNames(t *testing.T) {
+	tests := []struct {
+		line, from, to string
+	}{
+		{"waterloo - victoria", "waterloo", "victoria"},
+		{`"Tallinn-Väike"-Tartu`, "Tallinn-Väike", "Tartu"},
+		{`Ülemiste - "Tapa raudteejaam"`, "Ülemiste", "Tapa raudteejaam"},
+		{`"a, b"-"c \"d\""`, "a, b", `c "d"`},
+	}
+	for _, tt := range tests {
+		from, to, err := parseLinkNames(tt.line)
+		if err != nil || from != tt.from || to != tt.to {
+			t.Errorf("parseLinkNames(%q) = %q, %q, %v", tt.line, from, to, err)
+		}
+	}
+
+	_, _, err := parseLinkNames(`"Tallinn-Väike-Tartu`)
+	if err == nil || err.Error() != `unterminated quote in row '"Tallinn-Väike-Tartu'` {
+		t.Fatalf("Expected unterminated quote error, got: %v", err)
+	}
+	_, _, err = parseLinkNames("Tallinn-Väike-Tartu")
+	if err == nil || err.Error() != "connections section has fault in 'Tallinn' row: incorrect amount of stations in row" {
+		t.Fatalf("Expected incorrect amount of stations error, got: %v", err)
+	}
+}
+
+// TestFormatName tests that only names the parser would split are quoted and that they read back unchanged
+func TestFormatName(t *testing.T) {
+	tests := map[string]string{
+		"waterloo":         "waterloo",
+		"Ülemiste":         "Ülemiste",
+		"Tallinn-Väike":    `"Tallinn-Väike"`,
+		"Tapa raudteejaam": `"Tapa raudteejaam"`,
+		"a,b":              `"a,b"`,
+		"#1":               `"#1"`,
+		`say "hi"`:         `"say \"hi\""`,
+		"":                 `""`,
+	}
+	for name, expected := range tests {
+		formatted := formatName(name)
+		if formatted != expected {
+			t.Errorf("formatName(%q) = %s, want %s", name, formatted, expected)
+		}
+		parsed, err := parseName(formatted)
+		if err != nil || parsed != name {
+			t.Errorf("parseName(%s) = %q, %v, want %q", formatted, parsed, err, name)
+		}
+	}
+}
+
+// TestLoadNetworkMap_QuotedNames tests loading a map with hyphens, spaces and Unicode in station names
+func TestLoadNetworkMap_QuotedNames(t *testing.T) {
+	network, err := LoadNetworkMap("network12.map")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{"Kitseküla", "Tallinn-Väike", "Tapa raudteejaam", "Tartu", "Ülemiste"}
+	if names := network.sortedStationNames(); !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected stations %v, got %v", expected, names)
+	}
+	if !network.links["Tallinn-Väike"]["Ülemiste"] || !network.links["Tapa raudteejaam"]["Tartu"] {
+		t.Fatalf("Expected connections between quoted stations, got %v", network.links)
+	}
+}
+
+// FuzzFormatName tests that any pair of station names survives being written as a connection row and read back
+func FuzzFormatName(f *testing.F) {
+	for _, name := range []string{"waterloo", "Tallinn-Väike", "a,b", `say "hi"`, "# not a comment", "", " "} {
+		f.Add(name, "st_pancras")
+	}
+	f.Fuzz(func(t *testing.T, from, to string) {
+		line, _, _ := splitComment(formatRoute([]string{from, to}))
+		parsedFrom, parsedTo, err := parseLinkNames(line)
+		if err != nil {
+			t.Fatalf("cannot read back %q: %v", line, err)
+		}
+		if parsedFrom != from || parsedTo != to {
+			t.Fatalf("expected %q and %q, got %q and %q from %q", from, to, parsedFrom, parsedTo, line)
+		}
+	})
+}
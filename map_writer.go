@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// WriteNetworkMap writes the network in canonical .map format: stations sorted by name,
+// connections sorted by their station pair and comments kept above the line they belong to
+func (network *RailNetwork) WriteNetworkMap(w io.Writer) error {
+	out := bufio.NewWriter(w)
+
+	names := network.sortedStationNames()
+	for _, name := range names {
+		if !network.stations[name].placed {
+			return fmt.Errorf("station %s has no coordinates", name)
+		}
+	}
+
+	writeComments(out, network.header)
+	fmt.Fprintln(out, "stations:")
+	for _, name := range names {
+		location := network.stations[name]
+		writeComments(out, location.comments)
+		fmt.Fprintf(out, "%s,%d,%d", formatName(location.name), location.x, location.y)
+		for _, key := range sortedKeys(location.attributes) {
+			fmt.Fprintf(out, " %s=%s", key, formatName(location.attributes[key]))
+		}
+		fmt.Fprintln(out)
+	}
+
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "connections:")
+	for _, key := range network.sortedLinks() {
+		writeComments(out, network.linkComments[key])
+		fmt.Fprintln(out, formatRoute(key[:]))
+	}
+	writeComments(out, network.footer)
+
+	return out.Flush()
+}
+
+// FormatMapFile loads a map file and returns its contents in canonical layout.
+// Files with include directives are refused, formatting them would inline every included file
+func FormatMapFile(filename string, options LoadOptions) ([]byte, error) {
+	network, err := LoadNetworkMapWithOptions(filename, options)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if _, _, _, includeFound := checkSections(bufio.NewScanner(file)); includeFound {
+		return nil, fmt.Errorf("cannot format %s: it includes other map files", filename)
+	}
+	var buf bytes.Buffer
+	if err := network.WriteNetworkMap(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// sortedStationNames returns the names of all stations in lexicographic order
+func (network *RailNetwork) sortedStationNames() []string {
+	names := make([]string, 0, len(network.stations))
+	for name := range network.stations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sortedLinks returns every track once, ordered by its station pair
+func (network *RailNetwork) sortedLinks() [][2]string {
+	var keys [][2]string
+	for start, ends := range network.links {
+		for end := range ends {
+			if start < end {
+				keys = append(keys, [2]string{start, end})
+			}
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	return keys
+}
+
+// sortedKeys returns the keys of a station's attributes in lexicographic order
+func sortedKeys(attributes map[string]string) []string {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func writeComments(out io.Writer, comments []string) {
+	for _, comment := range comments {
+		if comment == "" {
+			fmt.Fprintln(out, "#")
+		} else {
+			fmt.Fprintln(out, "# "+comment)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeAndReload writes the network to a temporary file and loads it back
+func writeAndReload(t *testing.T, network *RailNetwork) (*RailNetwork, []byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := network.WriteNetworkMap(&buf); err != nil {
+		t.Fatalf("WriteNetworkMap failed: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "written.map")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := LoadNetworkMap(path)
+	if err != nil {
+		t.Fatalf("written map does not load: %v\n%s", err, buf.String())
+	}
+	return reloaded, buf.Bytes()
+}
+
+// every shipped map that loads must survive parse -> write -> parse without losing anything
+func TestWriteNetworkMap_RoundTripShippedMaps(t *testing.T) {
+	files, err := filepath.Glob("network*.map")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		network, err := LoadNetworkMap(file)
+		if err != nil {
+			continue // the network_err maps are meant to be rejected by the loader
+		}
+		t.Run(file, func(t *testing.T) {
+			reloaded, written := writeAndReload(t, network)
+			if !reflect.DeepEqual(network, reloaded) {
+				t.Fatalf("round trip changed the network\nwritten:\n%s", written)
+			}
+			_, rewritten := writeAndReload(t, reloaded)
+			if !bytes.Equal(written, rewritten) {
+				t.Fatalf("canonical output is not stable\nfirst:\n%s\nsecond:\n%s", written, rewritten)
+			}
+		})
+	}
+}
+
+// stations and connections built in code are written sorted with their comments
+func TestWriteNetworkMap_Canonical(t *testing.T) {
+	network := NewRailNetwork()
+	network.header = []string{"built in code"}
+	for i, name := range []string{"mozart", "beethoven", "part"} {
+		network.AddLocation(name)
+		if err := network.SetCoordinates(name, i, i+1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	network.stations["part"].comments = []string{"", "last stop"}
+	network.AddLink("part", "mozart")
+	network.AddLink("mozart", "beethoven")
+	network.linkComments[linkKey("part", "mozart")] = []string{"single track"}
+
+	var buf bytes.Buffer
+	if err := network.WriteNetworkMap(&buf); err != nil {
+		t.Fatalf("WriteNetworkMap failed: %v", err)
+	}
+	expected := `# built in code
+stations:
+beethoven,1,2
+mozart,0,1
+#
+# last stop
+part,2,3
+
+connections:
+beethoven-mozart
+# single track
+mozart-part
+`
+	if buf.String() != expected {
+		t.Fatalf("Expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+// stations without coordinates cannot be written
+func TestWriteNetworkMap_UnplacedStation(t *testing.T) {
+	network := NewRailNetwork()
+	network.AddLocation("beethoven")
+
+	err := network.WriteNetworkMap(&bytes.Buffer{})
+	if err == nil || err.Error() != "station beethoven has no coordinates" {
+		t.Fatalf("Expected 'station beethoven has no coordinates' error, got: %v", err)
+	}
+}
+
+// FormatMapFile normalizes spacing and moves inline comments above their line
+func TestFormatMapFile(t *testing.T) {
+	formatted, err := FormatMapFile("network1.map", DefaultLoadOptions)
+	if err != nil {
+		t.Fatalf("FormatMapFile failed: %v", err)
+	}
+	expected := `# network map
+stations:
+# north stations
+euston,11,23
+# international
+st_pancras,5,15
+victoria,6,7
+# south stations
+waterloo,3,1
+
+connections:
+euston-st_pancras
+euston-waterloo
+st_pancras-victoria
+victoria-waterloo
+`
+	if string(formatted) != expected {
+		t.Fatalf("Expected:\n%s\ngot:\n%s", expected, formatted)
+	}
+}
+
+// maps that include other files are not formatted, that would inline the included files
+func TestFormatMapFile_Include(t *testing.T) {
+	dir := writeMapFiles(t, map[string]string{
+		"national.map": "include \"north.map\"\n",
+		"north.map":    "stations:\ntallinn,0,0\n\nconnections:\n",
+	})
+	path := filepath.Join(dir, "national.map")
+	_, err := FormatMapFile(path, DefaultLoadOptions)
+	if err == nil || err.Error() != "cannot format "+path+": it includes other map files" {
+		t.Fatalf("Expected include error, got: %v", err)
+	}
+}
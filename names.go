@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// UnknownStationError is returned for a station name that is not in the network. Its message is the
+// usual "<role> station X does not exist", Suggestions holds the closest existing names
+type UnknownStationError struct {
+	Role        string // "source", "destination", "excluded" or empty for any other station
+	Name        string
+	Suggestions []string
+}
+
+func (err *UnknownStationError) Error() string {
+	if err.Role == "" {
+		return fmt.Sprintf("station %s does not exist", err.Name)
+	}
+	return fmt.Sprintf("%s station %s does not exist", err.Role, err.Name)
+}
+
+// Hint asks whether one of the suggestions was meant, empty when there are none
+func (err *UnknownStationError) Hint() string {
+	if len(err.Suggestions) == 0 {
+		return ""
+	}
+	var names []string
+	for _, name := range err.Suggestions {
+		names = append(names, formatName(name))
+	}
+	return "did you mean " + strings.Join(names, ", ") + "?"
+}
+
+// suggestionLimit is the most station names suggested for an unknown one
+const suggestionLimit = 3
+
+// checkEndpoints returns the error for the start and end stations of a route query, or nil when both exist and differ
+func (network *RailNetwork) checkEndpoints(source, destination string) error {
+	if source == destination {
+		return errors.New("source and destination stations are the same")
+	}
+	if err := network.checkStation("source", source); err != nil {
+		return err
+	}
+	return network.checkStation("destination", destination)
+}
+
+// checkStation returns an *UnknownStationError when the network has no station with the name
+func (network *RailNetwork) checkStation(role, name string) error {
+	if _, exists := network.stations[name]; exists {
+		return nil
+	}
+	return &UnknownStationError{Role: role, Name: name, Suggestions: network.SuggestStations(name)}
+}
+
+// SuggestStations returns the existing station names closest to name: the same name in another case
+// first, then names within a few typos, then names that start with it
+func (network *RailNetwork) SuggestStations(name string) []string {
+	lower := strings.ToLower(name)
+	// typos allowed grow with the length of the name, one in four characters
+	allowed := max(1, len([]rune(name))/4)
+
+	type suggestion struct {
+		name  string
+		score int
+	}
+	var found []suggestion
+	for _, station := range network.sortedStationNames() {
+		candidate := strings.ToLower(station)
+		switch distance := editDistance(lower, candidate); {
+		case distance <= allowed:
+			found = append(found, suggestion{station, distance})
+		case lower != "" && strings.HasPrefix(candidate, lower):
+			found = append(found, suggestion{station, allowed + 1})
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].score < found[j].score })
+
+	var names []string
+	for _, suggestion := range found[:min(suggestionLimit, len(found))] {
+		names = append(names, suggestion.name)
+	}
+	return names
+}
+
+// ResolveStation returns the station whose name equals name when case is ignored. A name that exists
+// as it is wins, several stations that differ only in case are an error
+func (network *RailNetwork) ResolveStation(role, name string) (string, error) {
+	if _, exists := network.stations[name]; exists {
+		return name, nil
+	}
+	var matches []string
+	for _, station := range network.sortedStationNames() {
+		if strings.EqualFold(station, name) {
+			matches = append(matches, station)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return name, network.checkStation(role, name)
+	case 1:
+		return matches[0], nil
+	default:
+		return name, fmt.Errorf("%s station %s matches several stations when case is ignored: %s", role, name, strings.Join(matches, ", "))
+	}
+}
+
+// ResolveEndpoints resolves the start and end stations of a route query with ResolveStation
+func (network *RailNetwork) ResolveEndpoints(source, destination string) (string, string, error) {
+	source, err := network.ResolveStation("source", source)
+	if err != nil {
+		return source, destination, err
+	}
+	destination, err = network.ResolveStation("destination", destination)
+	return source, destination, err
+}
+
+// editDistance is the Levenshtein distance between two strings, counted in runes
+func editDistance(a, b string) int {
+	first, second := []rune(a), []rune(b)
+	previous := make([]int, len(second)+1)
+	current := make([]int, len(second)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(first); i++ {
+		current[0] = i
+		for j := 1; j <= len(second); j++ {
+			cost := 1
+			if first[i-1] == second[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(second)]
+}
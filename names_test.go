@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// namedNetwork returns a network with the given stations and no tracks
+func namedNetwork(names ...string) *RailNetwork {
+	network := NewRailNetwork()
+	for _, name := range names {
+		network.AddLocation(name)
+	}
+	return network
+}
+
+// TestSuggestStations tests that case differences, typos and prefixes are suggested in that order
+func TestSuggestStations(t *testing.T) {
+	network := namedNetwork("Waterloo", "waterloo_east", "victoria", "st_pancras", "euston", "euston_square")
+
+	tests := []struct {
+		name     string
+		expected []string
+	}{
+		{"WATERLOO", []string{"Waterloo", "waterloo_east"}},
+		{"waterlo", []string{"Waterloo", "waterloo_east"}},
+		{"st_pancars", []string{"st_pancras"}},
+		{"vicotria", []string{"victoria"}},
+		{"eust", []string{"euston", "euston_square"}},
+		{"paddington", nil},
+	}
+	for _, test := range tests {
+		if suggestions := network.SuggestStations(test.name); !reflect.DeepEqual(suggestions, test.expected) {
+			t.Errorf("Expected %v for %s, got %v", test.expected, test.name, suggestions)
+		}
+	}
+}
+
+// TestSuggestStations_Limit tests that at most three names are suggested
+func TestSuggestStations_Limit(t *testing.T) {
+	network := namedNetwork("a1", "a2", "a3", "a4", "a5")
+	if suggestions := network.SuggestStations("a"); len(suggestions) != suggestionLimit {
+		t.Fatalf("Expected %d suggestions, got %v", suggestionLimit, suggestions)
+	}
+}
+
+// TestUnknownStationError tests that route queries keep their error messages and carry suggestions
+func TestUnknownStationError(t *testing.T) {
+	network := namedNetwork("waterloo", "st_pancras")
+	network.AddLink("waterloo", "st_pancras")
+
+	_, err := network.ExplorePaths("waterlo", "st_pancras")
+	if err == nil || err.Error() != "source station waterlo does not exist" {
+		t.Fatalf("Expected 'source station waterlo does not exist' error, got: %v", err)
+	}
+	var unknown *UnknownStationError
+	if !errors.As(err, &unknown) {
+		t.Fatalf("Expected an UnknownStationError, got: %v", err)
+	}
+	if unknown.Hint() != "did you mean waterloo?" {
+		t.Fatalf("Expected 'did you mean waterloo?', got: %s", unknown.Hint())
+	}
+
+	_, err = network.KShortestPaths("waterloo", "st_pancras", 1, PathOptions{Exclude: []string{"kings_cross"}})
+	if !errors.As(err, &unknown) || unknown.Role != "excluded" || unknown.Suggestions != nil {
+		t.Fatalf("Expected an excluded UnknownStationError without suggestions, got: %v", err)
+	}
+	if unknown.Hint() != "" {
+		t.Fatalf("Expected no hint, got: %s", unknown.Hint())
+	}
+}
+
+// TestResolveEndpoints tests the lookup that ignores case
+func TestResolveEndpoints(t *testing.T) {
+	network := namedNetwork("Waterloo", "st_pancras", "Euston", "EUSTON")
+
+	source, destination, err := network.ResolveEndpoints("waterloo", "ST_PANCRAS")
+	if err != nil || source != "Waterloo" || destination != "st_pancras" {
+		t.Fatalf("Expected Waterloo and st_pancras, got %s, %s, %v", source, destination, err)
+	}
+	// an exact name wins over names that only differ in case
+	if name, err := network.ResolveStation("source", "EUSTON"); err != nil || name != "EUSTON" {
+		t.Fatalf("Expected EUSTON, got %s, %v", name, err)
+	}
+	if _, err := network.ResolveStation("source", "euston"); err == nil || err.Error() != "source station euston matches several stations when case is ignored: EUSTON, Euston" {
+		t.Fatalf("Expected an ambiguous name error, got: %v", err)
+	}
+	var unknown *UnknownStationError
+	if _, _, err := network.ResolveEndpoints("waterloo", "victoria"); !errors.As(err, &unknown) || unknown.Role != "destination" {
+		t.Fatalf("Expected an unknown destination, got: %v", err)
+	}
+}
+
+// TestEditDistance tests the Levenshtein distance on a few pairs
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+		{"väike", "vaike", 1},
+		{"flaw", "lawn", 2},
+	}
+	for _, test := range tests {
+		if distance := editDistance(test.a, test.b); distance != test.expected {
+			t.Errorf("Expected %d between %s and %s, got %d", test.expected, test.a, test.b, distance)
+		}
+	}
+}
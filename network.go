@@ -2,24 +2,37 @@ package main
 
 import (
 	"fmt"
+	"sort"
 )
 
 // RailNetwork represents the railway network
 type RailNetwork struct {
 	stations map[string]*Location
 	links    map[string]map[string]bool
+
+	// comments kept from the map file so the network can be written back
+	header       []string
+	footer       []string
+	linkComments map[[2]string][]string
+
+	graph *compactGraph // built from links on demand, dropped whenever the network changes
 }
 
 // Location represents a station in the network
 type Location struct {
-	name string
+	name       string
+	x, y       int
+	placed     bool
+	comments   []string
+	attributes map[string]string // optional key=value metadata such as zone=2 or type=depot
 }
 
 // NewRailNetwork initializes a new railway network
 func NewRailNetwork() *RailNetwork {
 	return &RailNetwork{
-		stations: make(map[string]*Location),
-		links:    make(map[string]map[string]bool),
+		stations:     make(map[string]*Location),
+		links:        make(map[string]map[string]bool),
+		linkComments: make(map[[2]string][]string),
 	}
 }
 
@@ -28,6 +41,7 @@ func (network *RailNetwork) AddLocation(name string) {
 	if _, exists := network.stations[name]; !exists {
 		network.stations[name] = &Location{name: name}
 		network.links[name] = make(map[string]bool)
+		network.graph = nil
 	}
 }
 
@@ -44,5 +58,126 @@ func (network *RailNetwork) AddLink(start, end string) error {
 	}
 	network.links[start][end] = true
 	network.links[end][start] = true
+	network.graph = nil
+	return nil
+}
+
+// SetCoordinates places an existing station at the given coordinates
+func (network *RailNetwork) SetCoordinates(name string, x, y int) error {
+	location, exists := network.stations[name]
+	if !exists {
+		return fmt.Errorf("station %s does not exist", name)
+	}
+	location.x, location.y = x, y
+	location.placed = true
+	return nil
+}
+
+// SetAttribute stores a key=value attribute on an existing station
+func (network *RailNetwork) SetAttribute(name, key, value string) error {
+	location, exists := network.stations[name]
+	if !exists {
+		return fmt.Errorf("station %s does not exist", name)
+	}
+	if location.attributes == nil {
+		location.attributes = make(map[string]string)
+	}
+	location.attributes[key] = value
 	return nil
 }
+
+// Attribute returns the value of a station attribute and whether the station has it
+func (network *RailNetwork) Attribute(name, key string) (string, bool) {
+	location, exists := network.stations[name]
+	if !exists {
+		return "", false
+	}
+	value, found := location.attributes[key]
+	return value, found
+}
+
+// StationsWithAttribute returns the sorted names of the stations whose attribute key has the given value
+func (network *RailNetwork) StationsWithAttribute(key, value string) []string {
+	var names []string
+	for _, name := range network.sortedStationNames() {
+		if found, exists := network.stations[name].attributes[key]; exists && found == value {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Without returns a copy of the network where the given stations and their tracks are removed
+func (network *RailNetwork) Without(names ...string) *RailNetwork {
+	removed := make(map[string]bool)
+	for _, name := range names {
+		removed[name] = true
+	}
+	reduced := NewRailNetwork()
+	reduced.header, reduced.footer = network.header, network.footer
+	for name, location := range network.stations {
+		if !removed[name] {
+			copied := *location
+			reduced.stations[name] = &copied
+			reduced.links[name] = make(map[string]bool)
+		}
+	}
+	for start, ends := range network.links {
+		for end := range ends {
+			if !removed[start] && !removed[end] {
+				reduced.links[start][end] = true
+			}
+		}
+	}
+	for key, comments := range network.linkComments {
+		if !removed[key[0]] && !removed[key[1]] {
+			reduced.linkComments[key] = comments
+		}
+	}
+	return reduced
+}
+
+// WithoutLink returns a copy of the network where the track between two stations is removed
+func (network *RailNetwork) WithoutLink(start, end string) *RailNetwork {
+	reduced := network.Without()
+	delete(reduced.links[start], end)
+	delete(reduced.links[end], start)
+	delete(reduced.linkComments, linkKey(start, end))
+	return reduced
+}
+
+// linkKey returns the order independent key of the track between two stations
+func linkKey(a, b string) [2]string {
+	if b < a {
+		a, b = b, a
+	}
+	return [2]string{a, b}
+}
+
+// ConnectedComponents groups the stations into sets that are reachable from each other,
+// largest component first and every component sorted by station name
+func (network *RailNetwork) ConnectedComponents() [][]string {
+	seen := make(map[string]bool)
+	var components [][]string
+	for _, name := range network.sortedStationNames() {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		component := []string{name}
+		for i := 0; i < len(component); i++ {
+			for neighbor := range network.links[component[i]] {
+				if !seen[neighbor] {
+					seen[neighbor] = true
+					component = append(component, neighbor)
+				}
+			}
+		}
+		sort.Strings(component)
+		components = append(components, component)
+	}
+	sort.SliceStable(components, func(i, j int) bool {
+		return len(components[i]) > len(components[j])
+	})
+	return components
+}
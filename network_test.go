@@ -81,3 +81,29 @@ func TestAddLink(t *testing.T) {
 		t.Fatalf("Expected error message 'duplicate connection between beethoven and mozart', got: %v", err)
 	}
 }
+
+// TestWithout tests that removed stations take their tracks with them
+func TestWithout(t *testing.T) {
+	network := NewRailNetwork()
+	for _, name := range []string{"beethoven", "depot", "part"} {
+		network.AddLocation(name)
+	}
+	network.AddLink("beethoven", "depot")
+	network.AddLink("depot", "part")
+	network.AddLink("beethoven", "part")
+	network.SetAttribute("depot", "type", "depot")
+
+	reduced := network.Without(network.StationsWithAttribute("type", "depot")...)
+	if _, exists := reduced.stations["depot"]; exists {
+		t.Fatalf("Without did not remove station 'depot'")
+	}
+	if reduced.links["beethoven"]["depot"] || reduced.links["part"]["depot"] {
+		t.Fatalf("Without kept tracks to the removed station: %v", reduced.links)
+	}
+	if !reduced.links["beethoven"]["part"] {
+		t.Fatalf("Without removed the track between beethoven and part")
+	}
+	if _, exists := network.stations["depot"]; !exists || !network.links["beethoven"]["depot"] {
+		t.Fatalf("Without changed the original network")
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// parallelFor calls work for every index in [0, n) on up to workers goroutines and waits for all of them.
+// Work for different indexes must not share mutable state, results are written to per-index slots so
+// the outcome does not depend on scheduling. With one worker or less everything runs on the caller
+func parallelFor(workers, n int, work func(i int)) {
+	if workers <= 1 || n <= 1 {
+		for i := 0; i < n; i++ {
+			work(i)
+		}
+		return
+	}
+	workers = min(workers, n)
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := int(next.Add(1) - 1); i < n; i = int(next.Add(1) - 1) {
+				work(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+// TestParallelFor tests that every index is handled exactly once for any number of workers
+func TestParallelFor(t *testing.T) {
+	for _, workers := range []int{0, 1, 3, 16} {
+		counts := make([]int32, 100)
+		parallelFor(workers, len(counts), func(i int) {
+			atomic.AddInt32(&counts[i], 1)
+		})
+		for i, count := range counts {
+			if count != 1 {
+				t.Fatalf("workers %d: index %d handled %d times", workers, i, count)
+			}
+		}
+	}
+}
+
+// TestPlanContext_WorkersDeterministic tests that the number of workers does not change the routes,
+// the combinations or the plan
+func TestPlanContext_WorkersDeterministic(t *testing.T) {
+	type query struct {
+		network     *RailNetwork
+		source, end string
+		trains      int
+	}
+	tests := []query{{network: completeNetwork(7), source: "s00", end: "s06", trains: 12}}
+	for _, shipped := range [][3]string{
+		{"network7.map", "small", "large"},
+		{"network6.map", "jungle", "desert"},
+		{"network8.map", "beethoven", "part"},
+		{"network10.map", "beginning", "terminus"},
+	} {
+		network, err := LoadNetworkMap(shipped[0])
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		tests = append(tests, query{network: network, source: shipped[1], end: shipped[2], trains: 10})
+	}
+
+	for _, tt := range tests {
+		ctx := context.Background()
+		sequentialRoutes, _, err := tt.network.ExplorePathsContext(ctx, tt.source, tt.end, ExploreOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		sequentialCombos, _ := ValidateRoutesContext(ctx, sequentialRoutes, 1)
+		sequentialPlan, err := tt.network.PlanContext(ctx, tt.source, tt.end, tt.trains, PlanOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		for _, workers := range []int{2, 4, 8} {
+			routes, complete, err := tt.network.ExplorePathsContext(ctx, tt.source, tt.end, ExploreOptions{Workers: workers})
+			if err != nil || !complete {
+				t.Fatalf("Unexpected incomplete search: %v", err)
+			}
+			if !reflect.DeepEqual(routes, sequentialRoutes) {
+				t.Fatalf("%s-%s with %d workers: routes differ from a single worker", tt.source, tt.end, workers)
+			}
+			combos, _ := ValidateRoutesContext(ctx, routes, workers)
+			if !reflect.DeepEqual(combos, sequentialCombos) {
+				t.Fatalf("%s-%s with %d workers: combinations differ from a single worker", tt.source, tt.end, workers)
+			}
+			plan, err := tt.network.PlanContext(ctx, tt.source, tt.end, tt.trains, PlanOptions{ExploreOptions: ExploreOptions{Workers: workers}})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(plan, sequentialPlan) {
+				t.Fatalf("%s-%s with %d workers: plan differs from a single worker", tt.source, tt.end, workers)
+			}
+		}
+	}
+}
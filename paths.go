@@ -0,0 +1,269 @@
+package main
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// PathOptions changes how KShortestPaths measures and filters routes
+type PathOptions struct {
+	// Weight is the cost of the track between two connected stations, nil counts every track as 1
+	Weight func(from, to string) float64
+	// Exclude lists stations no route may pass through
+	Exclude []string
+}
+
+// WeightedRoute is a route together with its total cost
+type WeightedRoute struct {
+	Stations []string
+	Cost     float64
+}
+
+// DistanceWeight returns a weight that is the straight line distance between the station coordinates
+func DistanceWeight(network *RailNetwork) func(from, to string) float64 {
+	return func(from, to string) float64 {
+		a, b := network.stations[from], network.stations[to]
+		return math.Hypot(float64(a.x-b.x), float64(a.y-b.y))
+	}
+}
+
+// KShortestPaths returns up to k loopless routes from source to destination, cheapest first,
+// using Yen's algorithm. Routes of equal cost are ordered like routeLess orders them, so the routes
+// for k are always the first routes for k+1
+func (network *RailNetwork) KShortestPaths(source, destination string, k int, options PathOptions) ([]WeightedRoute, error) {
+	if err := network.checkEndpoints(source, destination); err != nil {
+		return nil, err
+	}
+	if k <= 0 {
+		return nil, errors.New("number of paths must be a valid positive integer")
+	}
+	if err := network.checkConnected(source, destination); err != nil {
+		return nil, err
+	}
+
+	graph := network.Compact()
+	search := newPathSearch(graph, options.Weight)
+	for _, name := range options.Exclude {
+		id, exists := graph.ids[name]
+		if !exists {
+			return nil, network.checkStation("excluded", name)
+		}
+		if name == source || name == destination {
+			return nil, fmt.Errorf("station %s is excluded", name)
+		}
+		search.excluded[id] = true
+	}
+	start, target := graph.ids[source], graph.ids[destination]
+
+	first, cost, found := search.shortest(start, target, nil, nil)
+	if !found {
+		return nil, errors.New("no routes found from start to end")
+	}
+	accepted := []pathCandidate{{path: first, cost: cost}}
+	var candidates []pathCandidate
+	seen := map[string]bool{pathKey(first): true}
+
+	for {
+		previous := accepted[len(accepted)-1].path
+		for i := 0; i < len(previous)-1; i++ {
+			spur := previous[i]
+			root := previous[:i+1]
+
+			// tracks leaving the root towards routes already found are closed for the spur search
+			blockedTracks := make(map[[2]int32]bool)
+			for _, candidate := range accepted {
+				if len(candidate.path) > i && equalIDs(candidate.path[:i+1], root) {
+					blockedTracks[[2]int32{candidate.path[i], candidate.path[i+1]}] = true
+				}
+			}
+			// the root stations before the spur may not be visited again
+			blockedStations := make(map[int32]bool)
+			for _, id := range root[:i] {
+				blockedStations[id] = true
+			}
+
+			spurPath, spurCost, found := search.shortest(spur, target, blockedStations, blockedTracks)
+			if !found {
+				continue
+			}
+			path := append(append([]int32{}, root[:i]...), spurPath...)
+			if seen[pathKey(path)] {
+				continue
+			}
+			seen[pathKey(path)] = true
+			candidates = append(candidates, pathCandidate{path: path, cost: search.cost(root) + spurCost})
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		sort.SliceStable(candidates, func(a, b int) bool {
+			return candidates[a].less(candidates[b], graph)
+		})
+		// routes as cheap as the k-th are all taken, which of them Yen's algorithm finds first is down to chance
+		if len(accepted) >= k && candidates[0].cost > accepted[k-1].cost {
+			break
+		}
+		accepted = append(accepted, candidates[0])
+		candidates = candidates[1:]
+	}
+
+	// Yen's algorithm finds routes of equal cost in no particular order, sort them like ExplorePaths does
+	// before keeping the first k
+	sort.SliceStable(accepted, func(a, b int) bool {
+		return accepted[a].less(accepted[b], graph)
+	})
+	accepted = accepted[:min(k, len(accepted))]
+	routes := make([]WeightedRoute, len(accepted))
+	for i, candidate := range accepted {
+		routes[i] = WeightedRoute{Stations: graph.route(candidate.path), Cost: candidate.cost}
+	}
+	return routes, nil
+}
+
+// pathCandidate is a route of station IDs and its cost
+type pathCandidate struct {
+	path []int32
+	cost float64
+}
+
+// less orders candidates by cost and then the way routeLess orders routes
+func (candidate pathCandidate) less(other pathCandidate, graph *compactGraph) bool {
+	if candidate.cost != other.cost {
+		return candidate.cost < other.cost
+	}
+	return routeLess(graph.route(candidate.path), graph.route(other.path))
+}
+
+// pathSearch runs Dijkstra's algorithm or A* on the compact graph with some stations excluded
+type pathSearch struct {
+	graph    *compactGraph
+	weight   func(from, to int32) float64
+	excluded []bool
+}
+
+func newPathSearch(graph *compactGraph, weight func(from, to string) float64) *pathSearch {
+	search := &pathSearch{
+		graph:    graph,
+		excluded: make([]bool, graph.stationCount()),
+		weight:   func(from, to int32) float64 { return 1 },
+	}
+	if weight != nil {
+		search.weight = func(from, to int32) float64 {
+			return weight(graph.names[from], graph.names[to])
+		}
+	}
+	return search
+}
+
+// cost returns the total weight of the tracks along a path
+func (search *pathSearch) cost(path []int32) float64 {
+	total := 0.0
+	for i := 1; i < len(path); i++ {
+		total += search.weight(path[i-1], path[i])
+	}
+	return total
+}
+
+// shortest returns the cheapest path from start to target that avoids excluded and blocked stations and blocked tracks
+func (search *pathSearch) shortest(start, target int32, blockedStations map[int32]bool, blockedTracks map[[2]int32]bool) ([]int32, float64, bool) {
+	return search.shortestGuided(start, target, blockedStations, blockedTracks, nil)
+}
+
+// shortestGuided is Dijkstra's algorithm turned into A* by estimate, a lower bound of the remaining
+// cost from a station to target. A nil estimate searches like plain Dijkstra
+func (search *pathSearch) shortestGuided(start, target int32, blockedStations map[int32]bool, blockedTracks map[[2]int32]bool, estimate func(id int32) float64) ([]int32, float64, bool) {
+	if estimate == nil {
+		estimate = func(int32) float64 { return 0 }
+	}
+	graph := search.graph
+	costs := make([]float64, graph.stationCount())
+	previous := make([]int32, graph.stationCount())
+	done := make([]bool, graph.stationCount())
+	for i := range costs {
+		costs[i] = math.Inf(1)
+		previous[i] = -1
+	}
+	costs[start] = 0
+	queue := &stationQueue{{id: start, priority: estimate(start)}}
+
+	for queue.Len() > 0 {
+		current := heap.Pop(queue).(queuedStation).id
+		if done[current] {
+			continue
+		}
+		done[current] = true
+		if current == target {
+			break
+		}
+		for _, neighbor := range graph.neighbors(current) {
+			if done[neighbor] || search.excluded[neighbor] || blockedStations[neighbor] ||
+				blockedTracks[[2]int32{current, neighbor}] {
+				continue
+			}
+			cost := costs[current] + search.weight(current, neighbor)
+			if cost < costs[neighbor] {
+				costs[neighbor] = cost
+				previous[neighbor] = current
+				heap.Push(queue, queuedStation{id: neighbor, priority: cost + estimate(neighbor)})
+			}
+		}
+	}
+	if !done[target] {
+		return nil, 0, false
+	}
+
+	var path []int32
+	for id := target; id >= 0; id = previous[id] {
+		path = append(path, id)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, costs[target], true
+}
+
+// pathKey identifies a path of station IDs in a map
+func pathKey(path []int32) string {
+	return fmt.Sprint(path)
+}
+
+// equalIDs reports whether two paths visit the same stations
+func equalIDs(a, b []int32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// queuedStation is a station waiting in the priority queue of a path search
+type queuedStation struct {
+	id       int32
+	priority float64
+}
+
+// stationQueue is a min-heap of stations by priority, ties go to the lower station ID
+type stationQueue []queuedStation
+
+func (queue stationQueue) Len() int { return len(queue) }
+func (queue stationQueue) Less(i, j int) bool {
+	if queue[i].priority != queue[j].priority {
+		return queue[i].priority < queue[j].priority
+	}
+	return queue[i].id < queue[j].id
+}
+func (queue stationQueue) Swap(i, j int)          { queue[i], queue[j] = queue[j], queue[i] }
+func (queue *stationQueue) Push(item interface{}) { *queue = append(*queue, item.(queuedStation)) }
+func (queue *stationQueue) Pop() interface{} {
+	old := *queue
+	item := old[len(old)-1]
+	*queue = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// TestKShortestPaths_MatchesExplorePaths tests that asking for more routes than exist returns every
+// route in the same order as ExplorePaths, which enumerates them all
+func TestKShortestPaths_MatchesExplorePaths(t *testing.T) {
+	for _, query := range shippedQueries {
+		network, err := LoadNetworkMap(query.file)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		expected, err := network.ExplorePaths(query.source, query.end)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		routes, err := network.KShortestPaths(query.source, query.end, len(expected)+5, PathOptions{})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", query.file, err)
+		}
+		var stations [][]string
+		for _, route := range routes {
+			stations = append(stations, route.Stations)
+			if route.Cost != float64(len(route.Stations)-1) {
+				t.Fatalf("%s: expected cost %d for %v, got %v", query.file, len(route.Stations)-1, route.Stations, route.Cost)
+			}
+		}
+		if !reflect.DeepEqual(stations, expected) {
+			t.Fatalf("%s: expected routes\n%v\ngot\n%v", query.file, expected, stations)
+		}
+	}
+}
+
+// TestKShortestPaths_Prefix tests on random networks that the first k routes are the first k routes
+// of ExplorePaths, also when routes of the same cost as the k-th route are left out
+func TestKShortestPaths_Prefix(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	for i := 0; i < 100; i++ {
+		network := randomConnectedNetwork(rng, 9, rng.Intn(12))
+		expected, err := network.ExplorePaths("r0", "r8")
+		if err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+		for k := 1; k < len(expected); k++ {
+			routes, err := network.KShortestPaths("r0", "r8", k, PathOptions{})
+			if err != nil {
+				t.Fatalf("case %d: unexpected error: %v", i, err)
+			}
+			var stations [][]string
+			for _, route := range routes {
+				stations = append(stations, route.Stations)
+			}
+			if !reflect.DeepEqual(stations, expected[:k]) {
+				t.Fatalf("case %d: expected the first %d routes\n%v\ngot\n%v", i, k, expected[:k], stations)
+			}
+		}
+	}
+}
+
+// TestKShortestPaths_WeightAndExclude tests distance weights and excluded stations
+func TestKShortestPaths_WeightAndExclude(t *testing.T) {
+	network := NewRailNetwork()
+	coordinates := map[string][2]int{"a": {0, 0}, "b": {5, 5}, "c": {1, 1}, "d": {2, 0}, "z": {3, 0}}
+	for name, xy := range coordinates {
+		network.AddLocation(name)
+		network.SetCoordinates(name, xy[0], xy[1])
+	}
+	network.AddLink("a", "b")
+	network.AddLink("b", "z")
+	network.AddLink("a", "c")
+	network.AddLink("c", "d")
+	network.AddLink("d", "z")
+
+	routes, err := network.KShortestPaths("a", "z", 2, PathOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(routes[0].Stations, []string{"a", "b", "z"}) {
+		t.Fatalf("Expected the route with fewest tracks first, got %v", routes[0].Stations)
+	}
+
+	routes, err = network.KShortestPaths("a", "z", 2, PathOptions{Weight: DistanceWeight(network)})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(routes[0].Stations, []string{"a", "c", "d", "z"}) || len(routes) != 2 {
+		t.Fatalf("Expected the shortest distance route first, got %v", routes)
+	}
+	if routes[0].Cost >= routes[1].Cost {
+		t.Fatalf("Expected routes ordered by cost, got %v", routes)
+	}
+
+	routes, err = network.KShortestPaths("a", "z", 5, PathOptions{Exclude: []string{"b"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(routes) != 1 || !reflect.DeepEqual(routes[0].Stations, []string{"a", "c", "d", "z"}) {
+		t.Fatalf("Expected only the route avoiding b, got %v", routes)
+	}
+
+	_, err = network.KShortestPaths("a", "z", 5, PathOptions{Exclude: []string{"c", "b"}})
+	if err == nil || err.Error() != "no routes found from start to end" {
+		t.Fatalf("Expected 'no routes found from start to end' error, got: %v", err)
+	}
+	_, err = network.KShortestPaths("a", "z", 5, PathOptions{Exclude: []string{"z"}})
+	if err == nil || err.Error() != "station z is excluded" {
+		t.Fatalf("Expected 'station z is excluded' error, got: %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// PlanOptions bounds the planner and sets how many goroutines it uses, zero values mean no limit
+type PlanOptions struct {
+	ExploreOptions
+	// Explain keeps the plan of every candidate combination in the result
+	Explain bool
+}
+
+// PlanResult is the schedule chosen by the planner
+type PlanResult struct {
+	Plan routePlan
+	// Suboptimal is set when the search was stopped by the context or a limit
+	// before every route was considered, a plan with fewer turns may exist
+	Suboptimal bool
+	// LowerBound is the number of turns no plan can beat, see TurnLowerBound
+	LowerBound int
+	// Candidates holds the plan of every combination that was considered, in the order
+	// SelectOptimalCombos gave them, when PlanOptions.Explain is set
+	Candidates []routePlan
+}
+
+// Gap returns how many turns the plan takes more than the lower bound, 0 means the plan is optimal
+func (result PlanResult) Gap() int {
+	return result.Plan.totalTurns - result.LowerBound
+}
+
+// Plan runs the whole planner: explore routes, combine them and allocate the trains
+func (network *RailNetwork) Plan(source, destination string, trainCount int) (routePlan, error) {
+	result, err := network.PlanContext(context.Background(), source, destination, trainCount, PlanOptions{})
+	return result.Plan, err
+}
+
+// PlanContext is Plan that honours the deadline and cancellation of ctx and the limits in options.
+// When it is stopped early it returns the best plan built from the routes found so far
+func (network *RailNetwork) PlanContext(ctx context.Context, source, destination string, trainCount int, options PlanOptions) (PlanResult, error) {
+	if trainCount <= 0 {
+		return PlanResult{}, fmt.Errorf("number of trains must be a valid positive integer")
+	}
+	routes, complete, err := network.ExplorePathsContext(ctx, source, destination, options.ExploreOptions)
+	if err != nil {
+		return PlanResult{}, err
+	}
+
+	validRoutes, validated := ValidateRoutesContext(ctx, routes, options.Workers)
+	optimalCombos := SelectOptimalCombos(validRoutes)
+	plans := distributeTrains(trainCount, optimalCombos, options.Workers)
+	plan := plans[bestPlanIndex(plans)]
+	bound, err := network.TurnLowerBound(source, destination, trainCount)
+	if err != nil {
+		return PlanResult{}, err
+	}
+
+	result := PlanResult{Plan: plan, Suboptimal: !complete || !validated, LowerBound: bound}
+	if options.Explain {
+		result.Candidates = plans
+	}
+	return result, nil
+}
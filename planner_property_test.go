@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// randomConnectedNetwork returns a network of n stations named r0, r1, ... joined by a random tree
+// and up to extra more random tracks
+func randomConnectedNetwork(rng *rand.Rand, n, extra int) *RailNetwork {
+	network := NewRailNetwork()
+	name := func(i int) string { return fmt.Sprintf("r%d", i) }
+	for i := 0; i < n; i++ {
+		network.AddLocation(name(i))
+		network.SetCoordinates(name(i), i, rng.Intn(1000))
+	}
+	for i := 1; i < n; i++ {
+		network.AddLink(name(rng.Intn(i)), name(i))
+	}
+	for i := 0; i < extra; i++ {
+		if a, b := rng.Intn(n), rng.Intn(n); a != b {
+			network.AddLink(name(a), name(b)) // a track that already exists is refused, which is fine here
+		}
+	}
+	return network
+}
+
+// optimalTurns is an exhaustive solver that shares no code with the planner: it tries every set of
+// routes without common intermediate stations and returns the fewest turns any set needs for all trains
+func optimalTurns(network *RailNetwork, source, destination string, trainCount int) int {
+	var routes [][]string
+	var walk func(route []string)
+	walk = func(route []string) {
+		last := route[len(route)-1]
+		if last == destination {
+			routes = append(routes, append([]string{}, route...))
+			return
+		}
+		for next := range network.links[last] {
+			if !contains(route, next) {
+				walk(append(route, next))
+			}
+		}
+	}
+	walk([]string{source})
+
+	// a route with n intermediate stations delivers its first train in turn n+1 and one more in every turn after
+	turns := func(intermediates []int) int {
+		for turn := 1; ; turn++ {
+			arrived := 0
+			for _, n := range intermediates {
+				if turn > n {
+					arrived += turn - n
+				}
+			}
+			if arrived >= trainCount {
+				return turn
+			}
+		}
+	}
+
+	best := -1
+	var choose func(from int, used map[string]bool, intermediates []int)
+	choose = func(from int, used map[string]bool, intermediates []int) {
+		if len(intermediates) > 0 {
+			if turn := turns(intermediates); best < 0 || turn < best {
+				best = turn
+			}
+		}
+		for i := from; i < len(routes); i++ {
+			middle := routes[i][1 : len(routes[i])-1]
+			free := true
+			for _, station := range middle {
+				free = free && !used[station]
+			}
+			if !free {
+				continue
+			}
+			for _, station := range middle {
+				used[station] = true
+			}
+			choose(i+1, used, append(intermediates, len(middle)))
+			for _, station := range middle {
+				delete(used, station)
+			}
+		}
+	}
+	choose(0, make(map[string]bool), nil)
+	return best
+}
+
+// checkSchedule replays a written schedule and fails unless every train moves along tracks only,
+// no two trains share an intermediate station or use the same track in a turn and all trains arrive
+func checkSchedule(t *testing.T, network *RailNetwork, source, destination string, trainCount int, schedule string) {
+	t.Helper()
+	positions := make(map[int]string, trainCount)
+	for id := 1; id <= trainCount; id++ {
+		positions[id] = source
+	}
+	checkScheduleFrom(t, network, source, destination, positions, schedule)
+}
+
+// checkScheduleFrom is checkSchedule for trains that start at the given positions, keyed by train number
+func checkScheduleFrom(t *testing.T, network *RailNetwork, source, destination string, positions map[int]string, schedule string) {
+	t.Helper()
+	trainCount := len(positions)
+	for turn, line := range strings.Split(strings.TrimSuffix(schedule, "\n"), "\n") {
+		moved := make(map[int]bool)
+		tracks := make(map[[2]string]bool)
+		for _, move := range strings.Fields(line) {
+			train, location, found := strings.Cut(strings.TrimPrefix(move, "T"), "-")
+			id, err := strconv.Atoi(train)
+			if !found || err != nil || id < 1 || id > trainCount {
+				t.Fatalf("turn %d: unexpected move %s", turn+1, move)
+			}
+			if location, err = parseName(location); err != nil {
+				t.Fatalf("turn %d: %v", turn+1, err)
+			}
+			from := positions[id]
+			if moved[id] || from == destination || !network.links[from][location] {
+				t.Fatalf("turn %d: T%d cannot move from %s to %s", turn+1, id, from, location)
+			}
+			if tracks[linkKey(from, location)] {
+				t.Fatalf("turn %d: two trains use the track %s-%s", turn+1, from, location)
+			}
+			moved[id] = true
+			tracks[linkKey(from, location)] = true
+			positions[id] = location
+		}
+
+		occupied := make(map[string]int)
+		for id, location := range positions {
+			if other, taken := occupied[location]; taken && location != source && location != destination {
+				t.Fatalf("turn %d: T%d and T%d are both at %s", turn+1, other, id, location)
+			}
+			occupied[location] = id
+		}
+	}
+
+	for id, location := range positions {
+		if location != destination {
+			t.Fatalf("T%d ended at %s instead of %s", id, location, destination)
+		}
+	}
+}
+
+// TestPlan_RandomNetworks tests on random connected networks that the schedule is valid, every train
+// arrives, the number of turns is the optimum found by the exhaustive solver and the lower bound is not above it
+func TestPlan_RandomNetworks(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	cases := 1000
+	if testing.Short() {
+		cases = 100
+	}
+	for i := 0; i < cases; i++ {
+		n := 2 + rng.Intn(9)
+		network := randomConnectedNetwork(rng, n, rng.Intn(2*n))
+		source, destination := "r0", fmt.Sprintf("r%d", n-1)
+		trains := 1 + rng.Intn(12)
+
+		plan, err := network.Plan(source, destination, trains)
+		if err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+		var buf bytes.Buffer
+		WriteSchedule(&buf, plan, trains)
+		if lines := strings.Count(buf.String(), "\n"); lines != plan.totalTurns {
+			t.Fatalf("case %d: expected %d lines, got %d", i, plan.totalTurns, lines)
+		}
+		checkSchedule(t, network, source, destination, trains, buf.String())
+
+		optimal := optimalTurns(network, source, destination, trains)
+		if bound, err := network.TurnLowerBound(source, destination, trains); err != nil || bound > optimal {
+			t.Fatalf("case %d: lower bound %d is above the optimum %d (%v)", i, bound, optimal, err)
+		}
+		if plan.totalTurns != optimal {
+			var written bytes.Buffer
+			network.WriteNetworkMap(&written)
+			t.Fatalf("case %d: %d trains from %s to %s take %d turns, the optimum is %d\n%s",
+				i, trains, source, destination, plan.totalTurns, optimal, written.String())
+		}
+	}
+}
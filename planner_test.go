@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// completeNetwork returns a network where every pair of its n stations is connected
+func completeNetwork(n int) *RailNetwork {
+	network := NewRailNetwork()
+	for i := 0; i < n; i++ {
+		network.AddLocation(fmt.Sprintf("s%02d", i))
+		network.SetCoordinates(fmt.Sprintf("s%02d", i), i, i)
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			network.AddLink(fmt.Sprintf("s%02d", i), fmt.Sprintf("s%02d", j))
+		}
+	}
+	return network
+}
+
+// TestPlan tests that Plan gives the same schedule as running the planning steps one by one
+func TestPlan(t *testing.T) {
+	network, err := LoadNetworkMap("network8.map")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	plan, err := network.Plan("beethoven", "part", 9)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	routes, _ := network.ExplorePaths("beethoven", "part")
+	expected := AllocateTrains(9, SelectOptimalCombos(ValidateRoutes(routes)))
+	if !reflect.DeepEqual(plan, expected) {
+		t.Fatalf("Expected %v, got %v", expected, plan)
+	}
+	if plan.totalTurns != 6 {
+		t.Fatalf("Expected 6 turns for 9 trains on network8.map, got %d", plan.totalTurns)
+	}
+}
+
+// TestPlanContext_Deadline tests that a search with too many routes to enumerate stops at the deadline
+func TestPlanContext_Deadline(t *testing.T) {
+	network := completeNetwork(14)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	result, err := network.PlanContext(ctx, "s00", "s13", 10, PlanOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Expected the planner to stop soon after the deadline, it ran for %v", elapsed)
+	}
+	if !result.Suboptimal {
+		t.Fatalf("Expected the plan to be flagged suboptimal")
+	}
+	if result.Plan.totalTurns == 0 {
+		t.Fatalf("Expected a plan from the routes found before the deadline")
+	}
+}
+
+// TestPlanContext_Cancelled tests that nothing is planned when the context is already cancelled
+func TestPlanContext_Cancelled(t *testing.T) {
+	network := completeNetwork(5)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := network.PlanContext(ctx, "s00", "s04", 3, PlanOptions{})
+	if err == nil || err.Error() != "no routes found from start to end before the search was stopped" {
+		t.Fatalf("Expected the search to be stopped, got: %v", err)
+	}
+}
+
+// TestExplorePathsContext_Limits tests the path length and path count limits
+func TestExplorePathsContext_Limits(t *testing.T) {
+	network := completeNetwork(6)
+
+	routes, complete, err := network.ExplorePathsContext(context.Background(), "s00", "s05", ExploreOptions{MaxPathLength: 3})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// the direct track and one route through each of the 4 other stations
+	if len(routes) != 5 || complete {
+		t.Fatalf("Expected 5 routes of at most 3 stations and an incomplete search, got %d routes, complete %v", len(routes), complete)
+	}
+	for _, route := range routes {
+		if len(route) > 3 {
+			t.Fatalf("Expected no route longer than 3 stations, got %v", route)
+		}
+	}
+
+	routes, complete, err = network.ExplorePathsContext(context.Background(), "s00", "s05", ExploreOptions{MaxPaths: 4})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(routes) != 4 || complete {
+		t.Fatalf("Expected 4 routes and an incomplete search, got %d routes, complete %v", len(routes), complete)
+	}
+	if !reflect.DeepEqual(routes[0], []string{"s00", "s05"}) {
+		t.Fatalf("Expected the direct track to be found first, got %v", routes[0])
+	}
+
+	// a limit the routes just fit in does not stop the search: the direct track and the one through s01
+	routes, complete, err = completeNetwork(3).ExplorePathsContext(context.Background(), "s00", "s02", ExploreOptions{MaxPaths: 2})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(routes) != 2 || !complete {
+		t.Fatalf("Expected 2 routes and a complete search, got %d routes, complete %v", len(routes), complete)
+	}
+
+	// the detour found through a must not push out the short route through d
+	detour := NewRailNetwork()
+	for _, name := range []string{"s", "a", "b", "c", "d", "t"} {
+		detour.AddLocation(name)
+	}
+	for _, link := range [][2]string{{"s", "a"}, {"a", "t"}, {"a", "b"}, {"b", "c"}, {"c", "t"}, {"s", "d"}, {"d", "t"}} {
+		detour.AddLink(link[0], link[1])
+	}
+	for _, workers := range []int{1, 2} {
+		routes, _, err = detour.ExplorePathsContext(context.Background(), "s", "t", ExploreOptions{MaxPaths: 2, Workers: workers})
+		if expected := [][]string{{"s", "a", "t"}, {"s", "d", "t"}}; err != nil || !reflect.DeepEqual(routes, expected) {
+			t.Fatalf("Expected the shortest routes %v with %d workers, got %v (%v)", expected, workers, routes, err)
+		}
+	}
+
+	_, complete, err = network.ExplorePathsContext(context.Background(), "s00", "s05", ExploreOptions{})
+	if err != nil || !complete {
+		t.Fatalf("Expected a complete search without limits, got complete %v, error %v", complete, err)
+	}
+}
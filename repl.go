@@ -0,0 +1,413 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// shell is an interactive session on a network loaded once: it plans and searches routes,
+// and closes and reopens stations and tracks without touching the map file
+type shell struct {
+	base           *RailNetwork
+	network        *RailNetwork // base without the closed stations and tracks
+	closedStations map[string]bool
+	closedLinks    map[[2]string]bool
+	ignoreCase     bool
+	timeout        time.Duration // limit of a single plan, 0 for no limit
+	cache          *PlanCache    // plans of earlier lines, closing and reopening a station finds them again
+	history        []string
+	out            io.Writer
+}
+
+func newShell(network *RailNetwork, out io.Writer) *shell {
+	cache, _ := NewPlanCache("", 100) // without a directory it cannot fail
+	return &shell{
+		cache:          cache,
+		base:           network,
+		network:        network,
+		closedStations: make(map[string]bool),
+		closedLinks:    make(map[[2]string]bool),
+		out:            out,
+	}
+}
+
+// shellCommands are the names the shell completes at the start of a line
+var shellCommands = []string{"close", "closed", "exit", "help", "history", "neighbors", "paths", "plan", "quit", "reopen", "stats"}
+
+const shellHelp = `plan <start> <end> <trains>   print the schedule of the trains
+paths <start> <end> [k]       print the k shortest routes, 5 by default
+neighbors <station>           list the stations one track away
+close station <station>       close a station until it is reopened
+close link <start> <end>      close the track between two stations
+reopen <station>              reopen a closed station
+reopen link <start> <end>     reopen a closed track
+closed                        list the closed stations and tracks
+stats                         print the statistics of the network with the closures
+history                       list the lines typed so far
+help                          print this list
+quit                          leave the shell
+Names with spaces or hyphens are written in double quotes like in map files.
+`
+
+// execute runs one line typed into the shell and reports whether the shell should stop
+func (sh *shell) execute(line string) (bool, error) {
+	words, err := splitWords(line)
+	if err != nil || len(words) == 0 {
+		return false, err
+	}
+	switch words[0] {
+	case "plan":
+		return false, sh.plan(words[1:])
+	case "paths":
+		return false, sh.paths(words[1:])
+	case "neighbors":
+		return false, sh.neighbors(words[1:])
+	case "close":
+		return false, sh.close(words[1:])
+	case "reopen":
+		return false, sh.reopen(words[1:])
+	case "closed":
+		sh.listClosed()
+	case "stats":
+		writeStats(sh.out, sh.network.Stats())
+	case "history":
+		for i, line := range sh.history {
+			fmt.Fprintf(sh.out, "%4d  %s\n", i+1, line)
+		}
+	case "help":
+		fmt.Fprint(sh.out, shellHelp)
+	case "quit", "exit":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown command %s, type help for the list of commands", words[0])
+	}
+	return false, nil
+}
+
+func (sh *shell) plan(args []string) error {
+	if len(args) != 3 {
+		return errors.New("usage: plan <start> <end> <trains>")
+	}
+	source, destination, err := sh.endpoints(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	trainCount, err := strconv.Atoi(args[2])
+	if err != nil || trainCount <= 0 {
+		return errors.New("number of trains must be a valid positive integer")
+	}
+
+	ctx := context.Background()
+	if sh.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, sh.timeout)
+		defer cancel()
+	}
+	options := PlanOptions{ExploreOptions: ExploreOptions{Workers: runtime.NumCPU()}}
+	result, _, err := sh.cache.Plan(ctx, sh.network, source, destination, trainCount, options)
+	if err != nil {
+		return err
+	}
+	WriteSchedule(sh.out, result.Plan, trainCount)
+	fmt.Fprintf(sh.out, "turns: %d, lower bound %d\n", result.Plan.totalTurns, result.LowerBound)
+	if result.Suboptimal {
+		fmt.Fprintln(sh.out, "the search was stopped early, the schedule may not be optimal")
+	}
+	return nil
+}
+
+func (sh *shell) paths(args []string) error {
+	if len(args) != 2 && len(args) != 3 {
+		return errors.New("usage: paths <start> <end> [k]")
+	}
+	source, destination, err := sh.endpoints(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	k := 5
+	if len(args) == 3 {
+		if k, err = strconv.Atoi(args[2]); err != nil || k <= 0 {
+			return errors.New("number of paths must be a valid positive integer")
+		}
+	}
+	routes, err := sh.network.KShortestPaths(source, destination, k, PathOptions{})
+	if err != nil {
+		return err
+	}
+	for i, route := range routes {
+		fmt.Fprintf(sh.out, "%d. %d tracks: %s\n", i+1, len(route.Stations)-1, formatRoute(route.Stations))
+	}
+	return nil
+}
+
+func (sh *shell) neighbors(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: neighbors <station>")
+	}
+	name, err := sh.openStation("", args[0])
+	if err != nil {
+		return err
+	}
+	var neighbors []string
+	for neighbor := range sh.network.links[name] {
+		neighbors = append(neighbors, neighbor)
+	}
+	if len(neighbors) == 0 {
+		fmt.Fprintf(sh.out, "%s has no open connections\n", formatName(name))
+		return nil
+	}
+	sort.Strings(neighbors)
+	for i, neighbor := range neighbors {
+		neighbors[i] = formatName(neighbor)
+	}
+	fmt.Fprintf(sh.out, "%s: %s\n", formatName(name), strings.Join(neighbors, ", "))
+	return nil
+}
+
+func (sh *shell) close(args []string) error {
+	switch {
+	case len(args) == 2 && args[0] == "station":
+		name, err := sh.station("", args[1])
+		if err != nil {
+			return err
+		}
+		if sh.closedStations[name] {
+			return fmt.Errorf("station %s is already closed", name)
+		}
+		sh.closedStations[name] = true
+		fmt.Fprintf(sh.out, "closed %s\n", formatName(name))
+	case len(args) == 3 && args[0] == "link":
+		key, err := sh.link(args[1], args[2])
+		if err != nil {
+			return err
+		}
+		if sh.closedLinks[key] {
+			return fmt.Errorf("connection between %s and %s is already closed", key[0], key[1])
+		}
+		sh.closedLinks[key] = true
+		fmt.Fprintf(sh.out, "closed %s\n", formatRoute(key[:]))
+	default:
+		return errors.New("usage: close station <station> or close link <start> <end>")
+	}
+	sh.applyClosures()
+	return nil
+}
+
+func (sh *shell) reopen(args []string) error {
+	switch {
+	case len(args) == 1 || len(args) == 2 && args[0] == "station":
+		name, err := sh.station("", args[len(args)-1])
+		if err != nil {
+			return err
+		}
+		if !sh.closedStations[name] {
+			return fmt.Errorf("station %s is not closed", name)
+		}
+		delete(sh.closedStations, name)
+		fmt.Fprintf(sh.out, "reopened %s\n", formatName(name))
+	case len(args) == 3 && args[0] == "link":
+		key, err := sh.link(args[1], args[2])
+		if err != nil {
+			return err
+		}
+		if !sh.closedLinks[key] {
+			return fmt.Errorf("connection between %s and %s is not closed", key[0], key[1])
+		}
+		delete(sh.closedLinks, key)
+		fmt.Fprintf(sh.out, "reopened %s\n", formatRoute(key[:]))
+	default:
+		return errors.New("usage: reopen <station> or reopen link <start> <end>")
+	}
+	sh.applyClosures()
+	return nil
+}
+
+func (sh *shell) listClosed() {
+	if len(sh.closedStations) == 0 && len(sh.closedLinks) == 0 {
+		fmt.Fprintln(sh.out, "nothing is closed")
+		return
+	}
+	var stations, links []string
+	for _, name := range sh.sortedClosedStations() {
+		stations = append(stations, formatName(name))
+	}
+	for _, key := range sh.sortedClosedLinks() {
+		links = append(links, formatRoute(key[:]))
+	}
+	fmt.Fprintf(sh.out, "closed stations: %d%s\n", len(stations), listSuffix(stations))
+	fmt.Fprintf(sh.out, "closed connections: %d%s\n", len(links), listSuffix(links))
+}
+
+// applyClosures rebuilds the network the commands work on from the loaded one
+func (sh *shell) applyClosures() {
+	network := sh.base.Without(sh.sortedClosedStations()...)
+	for _, key := range sh.sortedClosedLinks() {
+		network = network.WithoutLink(key[0], key[1])
+	}
+	sh.network = network
+}
+
+func (sh *shell) sortedClosedStations() []string {
+	var names []string
+	for name := range sh.closedStations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (sh *shell) sortedClosedLinks() [][2]string {
+	var keys [][2]string
+	for key := range sh.closedLinks {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	return keys
+}
+
+// station looks a name up in the loaded network, closed or not
+func (sh *shell) station(role, name string) (string, error) {
+	if sh.ignoreCase {
+		return sh.base.ResolveStation(role, name)
+	}
+	return name, sh.base.checkStation(role, name)
+}
+
+// openStation is station for a station that must not be closed
+func (sh *shell) openStation(role, name string) (string, error) {
+	name, err := sh.station(role, name)
+	if err == nil && sh.closedStations[name] {
+		err = fmt.Errorf("station %s is closed", name)
+	}
+	return name, err
+}
+
+func (sh *shell) endpoints(source, destination string) (string, string, error) {
+	source, err := sh.openStation("source", source)
+	if err != nil {
+		return source, destination, err
+	}
+	destination, err = sh.openStation("destination", destination)
+	return source, destination, err
+}
+
+// link returns the key of the track between two stations of the loaded network
+func (sh *shell) link(start, end string) ([2]string, error) {
+	start, err := sh.station("", start)
+	if err != nil {
+		return [2]string{}, err
+	}
+	if end, err = sh.station("", end); err != nil {
+		return [2]string{}, err
+	}
+	if !sh.base.links[start][end] {
+		return [2]string{}, fmt.Errorf("there is no connection between %s and %s", start, end)
+	}
+	return linkKey(start, end), nil
+}
+
+// complete returns where the word under the cursor starts in head, the text before the cursor,
+// and the words it can be completed to: a command, a keyword or a station name depending on its position
+func (sh *shell) complete(head string) (int, []string) {
+	start := 0
+	inQuotes := false
+	for i := 0; i < len(head); i++ {
+		switch head[i] {
+		case '\\':
+			if inQuotes {
+				i++
+			}
+		case '"':
+			inQuotes = !inQuotes
+		case ' ':
+			if !inQuotes {
+				start = i + 1
+			}
+		}
+	}
+	words, err := splitWords(head[:start])
+	if err != nil {
+		return start, nil
+	}
+
+	var options []string
+	switch {
+	case len(words) == 0:
+		options = shellCommands
+	case words[0] == "plan" && len(words) <= 2, words[0] == "paths" && len(words) <= 2,
+		words[0] == "neighbors" && len(words) == 1:
+		options = sh.network.sortedStationNames()
+	case words[0] == "close" && len(words) == 1:
+		options = []string{"link", "station"}
+	case words[0] == "close" && words[1] == "station" && len(words) == 2,
+		words[0] == "close" && words[1] == "link" && len(words) == 2:
+		options = sh.network.sortedStationNames()
+	case words[0] == "close" && words[1] == "link" && len(words) == 3:
+		for neighbor := range sh.network.links[words[2]] {
+			options = append(options, neighbor)
+		}
+	case words[0] == "reopen" && len(words) == 1:
+		options = append([]string{"link", "station"}, sh.sortedClosedStations()...)
+	case words[0] == "reopen" && words[1] == "station" && len(words) == 2:
+		options = sh.sortedClosedStations()
+	case words[0] == "reopen" && words[1] == "link" && len(words) <= 3:
+		for _, key := range sh.sortedClosedLinks() {
+			switch {
+			case len(words) == 2:
+				options = append(options, key[0], key[1])
+			case key[0] == words[2]:
+				options = append(options, key[1])
+			case key[1] == words[2]:
+				options = append(options, key[0])
+			}
+		}
+	}
+
+	prefix := head[start:]
+	seen := make(map[string]bool)
+	var candidates []string
+	for _, option := range options {
+		// commands and keywords are plain words that formatName leaves as they are
+		word := formatName(option)
+		matches := strings.HasPrefix(word, prefix) ||
+			sh.ignoreCase && len(word) >= len(prefix) && strings.EqualFold(word[:len(prefix)], prefix)
+		if matches && !seen[word] {
+			seen[word] = true
+			candidates = append(candidates, word)
+		}
+	}
+	sort.Strings(candidates)
+	return start, candidates
+}
+
+// splitWords splits a shell line on spaces, names in double quotes may contain spaces
+func splitWords(line string) ([]string, error) {
+	fields, err := splitFields(strings.TrimSpace(line), ' ')
+	if err != nil {
+		return nil, err
+	}
+	var words []string
+	for _, field := range fields {
+		if field == "" {
+			continue
+		}
+		word, err := parseName(field)
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, word)
+	}
+	return words, nil
+}
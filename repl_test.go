@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// replNetwork returns a square a-b-d-c-a with a tail d-e and a station with a quoted name off a
+func replNetwork() *RailNetwork {
+	network := NewRailNetwork()
+	for _, name := range []string{"a", "b", "c", "d", "e", "Tallinn-Väike"} {
+		network.AddLocation(name)
+	}
+	for _, link := range [][2]string{{"a", "b"}, {"b", "d"}, {"a", "c"}, {"c", "d"}, {"d", "e"}, {"a", "Tallinn-Väike"}} {
+		network.AddLink(link[0], link[1])
+	}
+	return network
+}
+
+// runShell executes the lines in the shell and returns what it printed, failing on any error
+func runShell(t *testing.T, sh *shell, lines ...string) string {
+	t.Helper()
+	var out bytes.Buffer
+	sh.out = &out
+	for _, line := range lines {
+		if _, err := sh.execute(line); err != nil {
+			t.Fatalf("Unexpected error for %q: %v", line, err)
+		}
+	}
+	return out.String()
+}
+
+// TestShell_CloseAndReopen tests that closures change the routes and are undone by reopening
+func TestShell_CloseAndReopen(t *testing.T) {
+	sh := newShell(replNetwork(), nil)
+
+	output := runShell(t, sh, "paths a e", "close station b", "paths a e", "neighbors a")
+	expected := "1. 3 tracks: a-b-d-e\n2. 3 tracks: a-c-d-e\n" +
+		"closed b\n" +
+		"1. 3 tracks: a-c-d-e\n" +
+		"a: \"Tallinn-Väike\", c\n"
+	if output != expected {
+		t.Fatalf("Expected:\n%s\ngot:\n%s", expected, output)
+	}
+
+	output = runShell(t, sh, "close link d c", "closed")
+	expected = "closed c-d\nclosed stations: 1 (b)\nclosed connections: 1 (c-d)\n"
+	if output != expected {
+		t.Fatalf("Expected:\n%s\ngot:\n%s", expected, output)
+	}
+	if _, err := sh.execute("plan a e 2"); err == nil || err.Error() != "no routes found from start to end" {
+		t.Fatalf("Expected 'no routes found from start to end' error, got: %v", err)
+	}
+
+	output = runShell(t, sh, "reopen b", "reopen link c d", "closed", "plan a e 2")
+	expected = "reopened b\nreopened c-d\nnothing is closed\n" +
+		"T1-b \nT1-d T2-b \nT1-e T2-d \nT2-e \nturns: 4, lower bound 4\n"
+	if output != expected {
+		t.Fatalf("Expected:\n%s\ngot:\n%s", expected, output)
+	}
+}
+
+// TestShell_Errors tests the messages for lines the shell cannot run
+func TestShell_Errors(t *testing.T) {
+	sh := newShell(replNetwork(), &bytes.Buffer{})
+	runShell(t, sh, "close station e")
+
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{"launch", "unknown command launch, type help for the list of commands"},
+		{"plan a b", "usage: plan <start> <end> <trains>"},
+		{"plan a b zero", "number of trains must be a valid positive integer"},
+		{"plan a x 1", "destination station x does not exist"},
+		{"plan a e 1", "station e is closed"},
+		{"neighbors e", "station e is closed"},
+		{"close station e", "station e is already closed"},
+		{"close link a d", "there is no connection between a and d"},
+		{"reopen a", "station a is not closed"},
+		{"reopen link a b", "connection between a and b is not closed"},
+		{"close a", "usage: close station <station> or close link <start> <end>"},
+		{`neighbors "a`, "unterminated quote in row 'neighbors \"a'"},
+	}
+	for _, test := range tests {
+		if _, err := sh.execute(test.line); err == nil || err.Error() != test.expected {
+			t.Errorf("Expected %q for %q, got: %v", test.expected, test.line, err)
+		}
+	}
+}
+
+// TestShell_QuotedNamesAndCase tests quoted station names and the lookup that ignores case
+func TestShell_QuotedNamesAndCase(t *testing.T) {
+	sh := newShell(replNetwork(), nil)
+	if output := runShell(t, sh, `neighbors "Tallinn-Väike"`); output != "\"Tallinn-Väike\": a\n" {
+		t.Fatalf("Unexpected output: %q", output)
+	}
+	if _, err := sh.execute("neighbors tallinn-väike"); err == nil {
+		t.Fatal("Expected an error for a name in the wrong case")
+	}
+	sh.ignoreCase = true
+	if output := runShell(t, sh, "neighbors tallinn-väike", "close station A"); output != "\"Tallinn-Väike\": a\nclosed a\n" {
+		t.Fatalf("Unexpected output: %q", output)
+	}
+}
+
+// TestShell_Quit tests that quit and exit stop the shell and other commands do not
+func TestShell_Quit(t *testing.T) {
+	sh := newShell(replNetwork(), &bytes.Buffer{})
+	for line, expected := range map[string]bool{"quit": true, "exit": true, "stats": false, "": false} {
+		if quit, _ := sh.execute(line); quit != expected {
+			t.Errorf("Expected %v for %q, got %v", expected, line, quit)
+		}
+	}
+}
+
+// TestShell_Complete tests the words offered for Tab at different positions of a line
+func TestShell_Complete(t *testing.T) {
+	sh := newShell(replNetwork(), &bytes.Buffer{})
+	runShell(t, sh, "close station c", "close link b d")
+
+	tests := []struct {
+		head     string
+		start    int
+		expected []string
+	}{
+		{"", 0, shellCommands},
+		{"pl", 0, []string{"plan"}},
+		{"clo", 0, []string{"close", "closed"}},
+		{"plan ", 5, []string{`"Tallinn-Väike"`, "a", "b", "d", "e"}},
+		{"plan a ", 7, []string{`"Tallinn-Väike"`, "a", "b", "d", "e"}},
+		{"plan a d ", 9, nil},
+		{`neighbors "Ta`, 10, []string{`"Tallinn-Väike"`}},
+		{"close ", 6, []string{"link", "station"}},
+		{"close link a ", 13, []string{`"Tallinn-Väike"`, "b"}},
+		{"reopen ", 7, []string{"c", "link", "station"}},
+		{"reopen link ", 12, []string{"b", "d"}},
+		{"reopen link d ", 14, []string{"b"}},
+		{"stats ", 6, nil},
+	}
+	for _, test := range tests {
+		start, candidates := sh.complete(test.head)
+		if start != test.start || !reflect.DeepEqual(candidates, test.expected) {
+			t.Errorf("Expected %d %v for %q, got %d %v", test.start, test.expected, test.head, start, candidates)
+		}
+	}
+
+	sh.ignoreCase = true
+	if _, candidates := sh.complete("plan A"); !reflect.DeepEqual(candidates, []string{"a"}) {
+		t.Errorf("Expected [a] when case is ignored, got %v", candidates)
+	}
+}
+
+// TestShell_Help tests that help mentions every command
+func TestShell_Help(t *testing.T) {
+	output := runShell(t, newShell(replNetwork(), nil), "help")
+	for _, command := range shellCommands {
+		if command != "exit" && !strings.Contains(output, command) {
+			t.Errorf("Expected help to mention %s", command)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ScheduleState is how far a schedule has run: the number of turns behind it and where every train
+// stands, Positions[0] being T1. Trains at the start have not left yet and trains at the end have arrived
+type ScheduleState struct {
+	Turn      int
+	Positions []string
+}
+
+// Disruption lists the stations and tracks closed while the trains are underway
+type Disruption struct {
+	Stations []string
+	Links    [][2]string
+}
+
+// Continuation is the rest of a schedule, planned from the state an earlier schedule had reached
+type Continuation struct {
+	// Start is the number of turns run before the first turn of Moves
+	Start int
+	// Moves holds the moves of every later turn written as T<train>-<station>, like scheduleMoves
+	Moves [][]string
+}
+
+// TotalTurns returns the number of turns from the very start until the last train arrives
+func (continuation Continuation) TotalTurns() int {
+	return continuation.Start + len(continuation.Moves)
+}
+
+// ReadSchedule reads a schedule written by WriteSchedule, one turn per line
+func ReadSchedule(r io.Reader) ([][]string, error) {
+	var schedule [][]string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields, err := splitFields(strings.TrimSpace(scanner.Text()), ' ')
+		if err != nil {
+			return nil, fmt.Errorf("turn %d: %v", len(schedule)+1, err)
+		}
+		moves := []string{}
+		for _, field := range fields {
+			if field != "" {
+				moves = append(moves, field)
+			}
+		}
+		schedule = append(schedule, moves)
+	}
+	return schedule, scanner.Err()
+}
+
+// parseMove splits a move written as T<train>-<station> into the train number and the station name
+func parseMove(move string) (int, string, error) {
+	train, station, found := strings.Cut(strings.TrimPrefix(move, "T"), "-")
+	id, err := strconv.Atoi(train)
+	if !strings.HasPrefix(move, "T") || !found || err != nil || id < 1 {
+		return 0, "", fmt.Errorf("unexpected move %s", move)
+	}
+	station, err = parseName(station)
+	return id, station, err
+}
+
+// StateAfter runs the first turns of a schedule for trainCount trains leaving source and returns where
+// they stand. Every move must follow a track of the network
+func (network *RailNetwork) StateAfter(schedule [][]string, source string, trainCount, turns int) (ScheduleState, error) {
+	if trainCount <= 0 {
+		return ScheduleState{}, fmt.Errorf("number of trains must be a valid positive integer")
+	}
+	if turns < 0 || turns > len(schedule) {
+		return ScheduleState{}, fmt.Errorf("turn %d is outside the schedule of %d turns", turns, len(schedule))
+	}
+	if err := network.checkStation("source", source); err != nil {
+		return ScheduleState{}, err
+	}
+	state := ScheduleState{Turn: turns, Positions: make([]string, trainCount)}
+	for i := range state.Positions {
+		state.Positions[i] = source
+	}
+	for turn, moves := range schedule[:turns] {
+		moved := make(map[int]bool)
+		for _, move := range moves {
+			id, station, err := parseMove(move)
+			if err == nil && id > trainCount {
+				err = fmt.Errorf("unexpected move %s, there are %d trains", move, trainCount)
+			}
+			if err != nil {
+				return ScheduleState{}, fmt.Errorf("turn %d: %v", turn+1, err)
+			}
+			from := state.Positions[id-1]
+			if moved[id] || !network.links[from][station] {
+				return ScheduleState{}, fmt.Errorf("turn %d: T%d cannot move from %s to %s", turn+1, id, from, station)
+			}
+			moved[id] = true
+			state.Positions[id-1] = station
+		}
+	}
+	return state, nil
+}
+
+// Replan plans the rest of a schedule after a disruption: every train goes on from where the state has
+// it, over the network without the closed stations and tracks, to destination. Trains may wait where
+// they stand or turn back, and as in every schedule no two trains end a turn on the same station other
+// than source and destination or use the same track in one turn. The trains are planned one after
+// another, each along the way that gets it to the end soonest around the trains planned before it,
+// starting with the trains underway that are closest to the end and then the trains still at the start
+func (network *RailNetwork) Replan(source, destination string, state ScheduleState, disruption Disruption) (Continuation, error) {
+	if err := network.checkEndpoints(source, destination); err != nil {
+		return Continuation{}, err
+	}
+	reduced, err := network.disrupt(source, destination, disruption)
+	if err != nil {
+		return Continuation{}, err
+	}
+	if err := reduced.checkConnected(source, destination); err != nil {
+		return Continuation{}, err
+	}
+	if len(state.Positions) == 0 {
+		return Continuation{}, fmt.Errorf("number of trains must be a valid positive integer")
+	}
+
+	graph := reduced.Compact()
+	start, end := graph.ids[source], graph.ids[destination]
+	distances := graph.distancesTo(end)
+	positions := make([]int32, len(state.Positions))
+	standing := make(map[string]int) // intermediate station -> train standing there
+	var underway, waiting []int
+	for i, name := range state.Positions {
+		id, exists := graph.ids[name]
+		switch {
+		case network.checkStation("", name) != nil:
+			return Continuation{}, fmt.Errorf("T%d is at %s, which does not exist", i+1, name)
+		case !exists:
+			return Continuation{}, fmt.Errorf("T%d is at %s, which is closed", i+1, name)
+		case distances[id] < 0:
+			return Continuation{}, fmt.Errorf("T%d is at %s, from where no track leads to %s any more", i+1, name, destination)
+		}
+		positions[i] = id
+		switch id {
+		case start:
+			waiting = append(waiting, i)
+		case end:
+		default:
+			if other, taken := standing[name]; taken {
+				return Continuation{}, fmt.Errorf("T%d and T%d are both at %s", other+1, i+1, name)
+			}
+			standing[name] = i
+			underway = append(underway, i)
+		}
+	}
+	sort.SliceStable(underway, func(a, b int) bool {
+		return distances[positions[underway[a]]] < distances[positions[underway[b]]]
+	})
+
+	// a train that cannot get through the trains planned before it is moved to the front and the
+	// trains are planned again, until every train has been first once
+	var paths map[int][]int32
+	for attempt := 0; ; attempt++ {
+		var stuck int
+		paths, stuck = planTrains(graph, start, end, positions, append(append([]int{}, underway...), waiting...))
+		if stuck < 0 {
+			break
+		}
+		if attempt >= len(underway) {
+			return Continuation{}, fmt.Errorf("T%d cannot reach %s without meeting another train", stuck+1, destination)
+		}
+		for i, train := range underway {
+			if train == stuck {
+				copy(underway[1:i+1], underway[:i])
+				underway[0] = stuck
+				break
+			}
+		}
+	}
+
+	continuation := Continuation{Start: state.Turn}
+	for train := range state.Positions {
+		path := paths[train]
+		for turn := 1; turn < len(path); turn++ {
+			if path[turn] == path[turn-1] {
+				continue
+			}
+			for len(continuation.Moves) < turn {
+				continuation.Moves = append(continuation.Moves, []string{})
+			}
+			move := fmt.Sprintf("T%d-%s", train+1, formatName(graph.names[path[turn]]))
+			continuation.Moves[turn-1] = append(continuation.Moves[turn-1], move)
+		}
+	}
+	return continuation, nil
+}
+
+// disrupt returns the network without the closed stations and tracks, which must exist
+func (network *RailNetwork) disrupt(source, destination string, disruption Disruption) (*RailNetwork, error) {
+	for _, name := range disruption.Stations {
+		if err := network.checkStation("closed", name); err != nil {
+			return nil, err
+		}
+		if name == source || name == destination {
+			return nil, fmt.Errorf("station %s cannot be closed, the trains start or end there", name)
+		}
+	}
+	reduced := network.Without(disruption.Stations...)
+	for _, link := range disruption.Links {
+		if !network.links[link[0]][link[1]] {
+			return nil, fmt.Errorf("there is no connection between %s and %s", link[0], link[1])
+		}
+		reduced = reduced.WithoutLink(link[0], link[1])
+	}
+	return reduced, nil
+}
+
+// turnSlot is a station, or the lower ID of the two stations of a track, at the end of a turn
+type turnSlot struct {
+	station int32
+	other   int32 // -1 for a station
+	turn    int
+}
+
+// planTrains finds for every train in order the earliest arrival at end that keeps clear of the stations
+// and tracks taken by the trains before it and of the stations where later trains stand. It returns the
+// station of every train at the end of every turn from turn 0, and the first train that found no way or -1
+func planTrains(graph *compactGraph, start, end int32, positions []int32, order []int) (map[int][]int32, int) {
+	taken := make(map[turnSlot]bool)
+	blocked := make(map[int32]bool) // stations where trains not planned yet stand
+	for _, train := range order {
+		if positions[train] != start {
+			blocked[positions[train]] = true
+		}
+	}
+	lastTaken := 0
+	free := func(station int32, turn int) bool {
+		return station == start || station == end || !blocked[station] && !taken[turnSlot{station, -1, turn}]
+	}
+
+	paths := make(map[int][]int32, len(positions))
+	for i := range positions {
+		if positions[i] == end {
+			paths[i] = []int32{end}
+		}
+	}
+	for _, train := range order {
+		delete(blocked, positions[train])
+		// once the trains before it are done a way to the end crosses every station at most once
+		path := earliestArrival(graph, positions[train], end, lastTaken+graph.stationCount()+1, func(from, to int32, turn int) bool {
+			a, b := min(from, to), max(from, to)
+			return (from == to || !taken[turnSlot{a, b, turn}]) && free(to, turn)
+		})
+		if path == nil {
+			return nil, train
+		}
+		for turn := 1; turn < len(path); turn++ {
+			from, to := path[turn-1], path[turn]
+			if to != start && to != end {
+				taken[turnSlot{to, -1, turn}] = true
+			}
+			if from != to {
+				taken[turnSlot{min(from, to), max(from, to), turn}] = true
+			}
+		}
+		lastTaken = max(lastTaken, len(path)-1)
+		paths[train] = path
+	}
+	return paths, -1
+}
+
+// earliestArrival searches the stations turn by turn from origin, waiting or moving along a track
+// whenever allowed says so, and returns the station at the end of every turn until end is reached,
+// or nil when end cannot be reached within horizon turns
+func earliestArrival(graph *compactGraph, origin, end int32, horizon int, allowed func(from, to int32, turn int) bool) []int32 {
+	if origin == end {
+		return []int32{end}
+	}
+	parents := []map[int32]int32{{origin: -1}} // parents[turn][station] is the station a turn earlier
+	frontier := []int32{origin}
+	for turn := 1; turn <= horizon && len(frontier) > 0; turn++ {
+		reached := make(map[int32]int32)
+		var next []int32
+		for _, station := range frontier {
+			for _, to := range append([]int32{station}, graph.neighbors(station)...) {
+				if _, seen := reached[to]; seen || !allowed(station, to, turn) {
+					continue
+				}
+				reached[to] = station
+				if to == end {
+					parents = append(parents, reached)
+					path := make([]int32, turn+1)
+					path[turn] = end
+					for back := turn; back > 0; back-- {
+						path[back-1] = parents[back][path[back]]
+					}
+					return path
+				}
+				next = append(next, to)
+			}
+		}
+		parents = append(parents, reached)
+		frontier = next
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// checkContinuation replays the continuation from the state on the network the disruption leaves
+func checkContinuation(t *testing.T, network *RailNetwork, source, destination string, state ScheduleState, disruption Disruption, continuation Continuation) {
+	t.Helper()
+	reduced, err := network.disrupt(source, destination, disruption)
+	if err != nil {
+		t.Fatal(err)
+	}
+	positions := make(map[int]string, len(state.Positions))
+	for i, station := range state.Positions {
+		positions[i+1] = station
+	}
+	var buf bytes.Buffer
+	writeMoves(&buf, continuation.Moves)
+	checkScheduleFrom(t, reduced, source, destination, positions, buf.String())
+}
+
+// TestReplan tests that the trains on network7 find their way around a station closed after two turns
+func TestReplan(t *testing.T) {
+	network, err := LoadNetworkMap("network7.map")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := network.Plan("small", "large", 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := network.StateAfter(scheduleMoves(plan, 9), "small", 9, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"11", "14", "01", "10", "13", "small", "small", "small", "small"}
+	if state.Turn != 2 || !reflect.DeepEqual(state.Positions, expected) {
+		t.Fatalf("Expected %v after turn 2, got %+v", expected, state)
+	}
+
+	disruption := Disruption{Stations: []string{"12"}}
+	continuation, err := network.Replan("small", "large", state, disruption)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkContinuation(t, network, "small", "large", state, disruption, continuation)
+	if continuation.Start != 2 || continuation.TotalTurns() != 10 {
+		t.Fatalf("Expected 8 turns after turn 2, got %d after %d", len(continuation.Moves), continuation.Start)
+	}
+
+	// without a disruption the trains are planned one by one and may take a turn longer than the plan
+	continuation, err = network.Replan("small", "large", state, Disruption{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkContinuation(t, network, "small", "large", state, Disruption{}, continuation)
+	if continuation.TotalTurns() < plan.totalTurns {
+		t.Fatalf("Expected no fewer turns than the optimal plan, got %d", continuation.TotalTurns())
+	}
+}
+
+// TestReplan_TurnBack tests that trains on a line cut ahead of them back out together and take the other way
+func TestReplan_TurnBack(t *testing.T) {
+	network := NewRailNetwork()
+	for _, name := range []string{"a", "x", "y", "w", "z"} {
+		network.AddLocation(name)
+	}
+	for _, link := range [][2]string{{"a", "x"}, {"x", "y"}, {"y", "z"}, {"a", "w"}, {"w", "z"}} {
+		network.AddLink(link[0], link[1])
+	}
+	state := ScheduleState{Turn: 2, Positions: []string{"y", "x", "a"}}
+	disruption := Disruption{Links: [][2]string{{"z", "y"}}}
+	continuation, err := network.Replan("a", "z", state, disruption)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkContinuation(t, network, "a", "z", state, disruption, continuation)
+	expected := [][]string{{"T1-x", "T2-a", "T3-w"}, {"T1-a", "T2-w", "T3-z"}, {"T1-w", "T2-z"}, {"T1-z"}}
+	if !reflect.DeepEqual(continuation.Moves, expected) {
+		t.Fatalf("Expected %v, got %v", expected, continuation.Moves)
+	}
+}
+
+// TestReplan_RandomNetworks tests on random networks that the trains of a schedule interrupted at a
+// random turn by a random closure either all arrive without meeting or get an error saying why not
+func TestReplan_RandomNetworks(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	cases := 500
+	if testing.Short() {
+		cases = 50
+	}
+	for i := 0; i < cases; i++ {
+		n := 3 + rng.Intn(10)
+		network := randomConnectedNetwork(rng, n, rng.Intn(2*n))
+		source, destination := "r0", fmt.Sprintf("r%d", n-1)
+		trains := 1 + rng.Intn(10)
+		plan, err := network.Plan(source, destination, trains)
+		if err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+		schedule := scheduleMoves(plan, trains)
+		state, err := network.StateAfter(schedule, source, trains, rng.Intn(len(schedule)+1))
+		if err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+
+		var disruption Disruption
+		if closed := fmt.Sprintf("r%d", 1+rng.Intn(n-2)); rng.Intn(2) == 0 {
+			disruption.Stations = []string{closed}
+		} else {
+			graph := network.Compact()
+			neighbors := graph.neighbors(graph.ids[closed])
+			disruption.Links = [][2]string{{closed, graph.names[neighbors[rng.Intn(len(neighbors))]]}}
+		}
+		continuation, err := network.Replan(source, destination, state, disruption)
+		var noRoute *NoRouteError
+		switch {
+		case err == nil:
+			checkContinuation(t, network, source, destination, state, disruption, continuation)
+		case errors.As(err, &noRoute), strings.Contains(err.Error(), "closed"), strings.Contains(err.Error(), "any more"):
+		default:
+			t.Fatalf("case %d: unexpected error for %+v from %+v: %v", i, disruption, state, err)
+		}
+	}
+}
+
+// TestReplan_Errors tests the messages for states and disruptions that cannot be replanned
+func TestReplan_Errors(t *testing.T) {
+	network := replNetwork()
+	tests := []struct {
+		positions  []string
+		disruption Disruption
+		expected   string
+	}{
+		{[]string{"b"}, Disruption{Stations: []string{"b"}}, "T1 is at b, which is closed"},
+		{[]string{"b", "b"}, Disruption{}, "T1 and T2 are both at b"},
+		{[]string{"f"}, Disruption{}, "T1 is at f, which does not exist"},
+		{[]string{"Tallinn-Väike"}, Disruption{Links: [][2]string{{"a", "Tallinn-Väike"}}}, "T1 is at Tallinn-Väike, from where no track leads to e any more"},
+		{[]string{"a"}, Disruption{Stations: []string{"a"}}, "station a cannot be closed, the trains start or end there"},
+		{[]string{"a"}, Disruption{Stations: []string{"x"}}, "closed station x does not exist"},
+		{[]string{"a"}, Disruption{Links: [][2]string{{"a", "d"}}}, "there is no connection between a and d"},
+		{[]string{"a"}, Disruption{Stations: []string{"d"}}, "no routes found from start to end"},
+		{nil, Disruption{}, "number of trains must be a valid positive integer"},
+	}
+	for _, test := range tests {
+		_, err := network.Replan("a", "e", ScheduleState{Positions: test.positions}, test.disruption)
+		if err == nil || err.Error() != test.expected {
+			t.Errorf("Expected %q for %v and %+v, got: %v", test.expected, test.positions, test.disruption, err)
+		}
+	}
+}
+
+// TestStateAfter tests reading a written schedule back and running part of it
+func TestStateAfter(t *testing.T) {
+	network, err := LoadNetworkMap("network12.map")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := network.Plan("Tallinn-Väike", "Tartu", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	WriteSchedule(&buf, plan, 4)
+	schedule, err := ReadSchedule(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(schedule, scheduleMoves(plan, 4)) {
+		t.Fatalf("Expected the written schedule back, got %v", schedule)
+	}
+	state, err := network.StateAfter(schedule, "Tallinn-Väike", 4, len(schedule))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, station := range state.Positions {
+		if station != "Tartu" {
+			t.Fatalf("Expected T%d in Tartu at the end, got %s", i+1, station)
+		}
+	}
+
+	tests := []struct {
+		schedule [][]string
+		turns    int
+		expected string
+	}{
+		{[][]string{{"T1-b"}}, 2, "turn 2 is outside the schedule of 1 turns"},
+		{[][]string{{"T1-d"}}, 1, "turn 1: T1 cannot move from a to d"},
+		{[][]string{{"T1-b", "T1-d"}}, 1, "turn 1: T1 cannot move from b to d"},
+		{[][]string{{"T3-b"}}, 1, "turn 1: unexpected move T3-b, there are 2 trains"},
+		{[][]string{{"X1-b"}}, 1, "turn 1: unexpected move X1-b"},
+	}
+	for _, test := range tests {
+		if _, err := replNetwork().StateAfter(test.schedule, "a", 2, test.turns); err == nil || err.Error() != test.expected {
+			t.Errorf("Expected %q for %v, got: %v", test.expected, test.schedule, err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// planReport is the JSON form of a planning result
+type planReport struct {
+	Start      string        `json:"start"`
+	End        string        `json:"end"`
+	Trains     int           `json:"trains"`
+	Turns      int           `json:"turns"`
+	LowerBound int           `json:"lowerBound"`
+	Gap        int           `json:"gap"`
+	Suboptimal bool          `json:"suboptimal"`
+	Routes     []routeReport `json:"routes"`
+	Schedule   [][]string    `json:"schedule"`
+	// Explanation is set when the plan was made with PlanOptions.Explain
+	Explanation *planExplanation `json:"explanation,omitempty"`
+}
+
+// routeReport is a route of the plan and the number of trains sent along it
+type routeReport struct {
+	Stations []string `json:"stations"`
+	Trains   int      `json:"trains"`
+}
+
+// newPlanReport collects everything about a planning result that is printed as JSON
+func newPlanReport(result PlanResult, source, destination string, trainCount int) planReport {
+	report := planReport{
+		Start:      source,
+		End:        destination,
+		Trains:     trainCount,
+		Turns:      result.Plan.totalTurns,
+		LowerBound: result.LowerBound,
+		Gap:        result.Gap(),
+		Suboptimal: result.Suboptimal,
+		Routes:     planRoutes(result.Plan, false),
+		Schedule:   scheduleMoves(result.Plan, trainCount),
+	}
+	if len(result.Candidates) > 0 {
+		explanation := explainPlan(result)
+		report.Explanation = &explanation
+	}
+	return report
+}
+
+// WritePlanJSON writes the planning result, its lower bound and schedule to w as indented JSON
+func WritePlanJSON(w io.Writer, result PlanResult, source, destination string, trainCount int) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(newPlanReport(result, source, destination, trainCount))
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// TestWritePlanJSON tests the JSON report of a plan on network3.map
+func TestWritePlanJSON(t *testing.T) {
+	network, err := LoadNetworkMap("network3.map")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	result, err := network.PlanContext(context.Background(), "waterloo", "st_pancras", 2, PlanOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WritePlanJSON(&buf, result, "waterloo", "st_pancras", 2); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var report planReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("Invalid JSON: %v\n%s", err, buf.String())
+	}
+
+	expected := planReport{
+		Start: "waterloo", End: "st_pancras", Trains: 2, Turns: 2, LowerBound: 2,
+		Routes: []routeReport{
+			{Stations: []string{"waterloo", "euston", "st_pancras"}, Trains: 1},
+			{Stations: []string{"waterloo", "victoria", "st_pancras"}, Trains: 1},
+		},
+		Schedule: [][]string{{"T1-euston", "T2-victoria"}, {"T1-st_pancras", "T2-st_pancras"}},
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, report)
+	}
+}
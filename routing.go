@@ -1,94 +1,260 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"os"
 	"sort"
 )
 
-// ExplorePaths uses BFS to find all routes from source to destination
+// ExploreOptions bounds and spreads the path search, zero values mean no limit
+type ExploreOptions struct {
+	MaxPathLength int // most stations on one route, start and end included
+	MaxPaths      int // most routes returned
+	Workers       int // goroutines searching in parallel, 0 or 1 searches on the calling goroutine
+}
+
+// ExplorePaths finds all routes from source to destination, shortest first
 func (network *RailNetwork) ExplorePaths(source, destination string) ([][]string, error) {
-	if source == destination {
-		return nil, errors.New("source and destination stations are the same")
+	routes, _, err := network.ExplorePathsContext(context.Background(), source, destination, ExploreOptions{})
+	return routes, err
+}
+
+// ExplorePathsContext is ExplorePaths that stops when ctx is done or a limit is reached.
+// complete is false when the search was cut short, the routes found until then are still returned.
+// With several workers the search is split on the first station after the source; the results
+// are merged in the same order as a single worker would find them
+func (network *RailNetwork) ExplorePathsContext(ctx context.Context, source, destination string, options ExploreOptions) ([][]string, bool, error) {
+	if err := network.checkEndpoints(source, destination); err != nil {
+		return nil, false, err
 	}
-	if _, exists := network.stations[source]; !exists {
-		return nil, fmt.Errorf("source station %s does not exist", source)
+	// stations in different parts of the network need no search, and the error tells what is missing
+	if err := network.checkConnected(source, destination); err != nil {
+		return nil, true, err
 	}
-	if _, exists := network.stations[destination]; !exists {
-		return nil, fmt.Errorf("destination station %s does not exist", destination)
+
+	graph := network.Compact()
+	search := &routeSearch{
+		ctx:       ctx,
+		graph:     graph,
+		target:    graph.ids[destination],
+		options:   options,
+		distances: graph.distancesTo(graph.ids[destination]),
 	}
+	start := graph.ids[source]
+	firstHops := search.candidates(start)
+
+	found := make([][][]string, len(firstHops))
+	finished := make([]bool, len(firstHops))
+	parallelFor(options.Workers, len(firstHops), func(i int) {
+		found[i], finished[i] = search.run([]int32{start, firstHops[i]})
+	})
 
-	queue := [][]string{{source}}
+	complete := true
 	var routes [][]string
+	for i := range firstHops {
+		routes = append(routes, found[i]...)
+		complete = complete && finished[i]
+	}
+	// sorted before the cut so a limited search keeps the shortest routes any worker found
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routeLess(routes[i], routes[j])
+	})
+	if options.MaxPaths > 0 && len(routes) > options.MaxPaths {
+		routes = routes[:options.MaxPaths]
+		complete = false
+	}
+	if len(routes) == 0 {
+		if !complete {
+			return nil, false, errors.New("no routes found from start to end before the search was stopped")
+		}
+		return nil, true, errors.New("no routes found from start to end")
+	}
 
-	for len(queue) > 0 {
-		route := queue[0]
-		queue = queue[1:]
-		current := route[len(route)-1]
+	return routes, complete, nil
+}
 
-		if current == destination {
-			routes = append(routes, route)
-			continue
-		}
+// routeSearch is the read-only state shared by all workers of one path search
+type routeSearch struct {
+	ctx       context.Context
+	graph     *compactGraph
+	target    int32
+	options   ExploreOptions
+	distances []int32 // tracks on the shortest way to the target, -1 when it cannot be reached
+}
 
-		for neighbor := range network.links[current] {
-			if !contains(route, neighbor) {
-				newRoute := append([]string{}, route...)
-				newRoute = append(newRoute, neighbor)
-				queue = append(queue, newRoute)
-			}
+// candidates returns the neighbors of a station in the order they are tried. When the number of
+// routes is limited, stations closest to the target come first so the first routes found are short
+func (search *routeSearch) candidates(id int32) []int32 {
+	neighbors := search.graph.neighbors(id)
+	if search.options.MaxPaths == 0 {
+		return neighbors
+	}
+	ordered := append([]int32{}, neighbors...)
+	sort.SliceStable(ordered, func(a, b int) bool {
+		return search.distances[ordered[a]] < search.distances[ordered[b]]
+	})
+	return ordered
+}
+
+// run finds the routes that begin with prefix. Depth-first search with one shared visited flag per
+// station keeps memory bounded by the size of the network plus the routes found
+func (search *routeSearch) run(prefix []int32) ([][]string, bool) {
+	graph, distances, limits := search.graph, search.distances, search.options
+	visited := make([]bool, graph.stationCount())
+	var path []int32
+	var candidates [][]int32
+	complete := true
+	var routes [][]string
+
+	// the prefix is entered like any other step, so the first hop passes the same checks
+	push := func(id int32) bool {
+		if visited[id] || distances[id] < 0 {
+			return false
 		}
+		// the shortest way on from this station would already make the route too long
+		if limits.MaxPathLength > 0 && len(path)+1+int(distances[id]) > limits.MaxPathLength {
+			complete = false
+			return false
+		}
+		visited[id] = true
+		path = append(path, id)
+		candidates = append(candidates, search.candidates(id))
+		return true
 	}
-	if len(routes) == 0 {
-		return nil, errors.New("no routes found from start to end")
+	for _, id := range prefix {
+		if !push(id) {
+			return nil, complete
+		}
 	}
 
-	return routes, nil
+	for steps := 0; len(path) > len(prefix)-1; steps++ {
+		if steps%1024 == 0 && search.ctx.Err() != nil {
+			complete = false
+			break
+		}
+		top := len(path) - 1
+		current := path[top]
+		if current == search.target || len(candidates[top]) == 0 {
+			if current == search.target {
+				routes = append(routes, graph.route(path))
+				// one route past the limit shows that the limit cut the search short
+				if limits.MaxPaths > 0 && len(routes) > limits.MaxPaths {
+					complete = false
+					break
+				}
+			}
+			visited[current] = false
+			path, candidates = path[:top], candidates[:top]
+			continue
+		}
+		neighbor := candidates[top][0]
+		candidates[top] = candidates[top][1:]
+		push(neighbor)
+	}
+	return routes, complete
 }
 
 // ValidateRoutes filters and returns valid route combinations without overlaps
 func ValidateRoutes(routes [][]string) [][][]string {
+	validCombos, _ := ValidateRoutesContext(context.Background(), routes, 1)
+	return validCombos
+}
+
+// ValidateRoutesContext is ValidateRoutes that builds the combinations on up to workers goroutines
+// and stops when ctx is done. complete is false when it was stopped, the combinations built until
+// then are returned
+func ValidateRoutesContext(ctx context.Context, routes [][]string, workers int) ([][][]string, bool) {
+	// The combination starting from each route does not depend on the others, so with several workers
+	// they are built in parallel up front. Picking which of them to keep stays sequential and gives
+	// the same result as a single worker, which only builds the combinations it keeps
+	combos := make([][]comboMember, len(routes))
+	built := make([]bool, len(routes))
+	if workers > 1 {
+		parallelFor(workers, len(routes), func(i int) {
+			// the first combination is always built so there is something to plan with
+			if i > 0 && ctx.Err() != nil {
+				return
+			}
+			combos[i] = comboFrom(routes, i)
+			built[i] = true
+		})
+	}
+
 	var validCombos [][][]string
 	used := make(map[int]bool) // Hoidke marsruutide indekseid, mida juba kasutati kombodes
-
-	for i, route := range routes {
+	complete := true
+	for i := range routes {
 		if used[i] {
 			continue
 		}
-
-		var occupiedStations []string
-		occupiedStations = append(occupiedStations, route[1:len(route)-1]...)
-		combo := [][]string{route}
-		used[i] = true
-
-		for j, otherRoute := range routes {
-			if i == j {
-				continue
-			}
-			valid := true
-			for _, station := range otherRoute[1 : len(otherRoute)-1] {
-				if contains(occupiedStations, station) {
-					valid = false
-					break
-				}
-			}
-			if valid {
-				combo = append(combo, otherRoute)
-				occupiedStations = append(occupiedStations, otherRoute[1:len(otherRoute)-1]...)
-				used[j] = true
+		if !built[i] {
+			if workers > 1 || (len(validCombos) > 0 && ctx.Err() != nil) {
+				complete = false
+				break
 			}
+			combos[i] = comboFrom(routes, i)
 		}
-
-		validCombos = append(validCombos, combo)
+		for _, member := range combos[i] {
+			used[member.index] = true
+		}
+		validCombos = append(validCombos, comboRoutes(combos[i]))
 	}
 
 	for _, routes := range validCombos {
 		sort.SliceStable(routes, func(i, j int) bool {
-			return len(routes[i]) < len(routes[j])
+			return routeLess(routes[i], routes[j])
 		})
 	}
 
-	return validCombos
+	return validCombos, complete
+}
+
+// comboMember is a route in a combination together with its index in the explored routes
+type comboMember struct {
+	index int
+	route []string
+}
+
+// comboFrom greedily adds to routes[i] every other route that shares no intermediate station with the combination so far
+func comboFrom(routes [][]string, i int) []comboMember {
+	route := routes[i]
+	occupiedStations := make(map[string]bool)
+	for _, station := range route[1 : len(route)-1] {
+		occupiedStations[station] = true
+	}
+	combo := []comboMember{{index: i, route: route}}
+
+	for j, otherRoute := range routes {
+		if i == j {
+			continue
+		}
+		valid := true
+		for _, station := range otherRoute[1 : len(otherRoute)-1] {
+			if occupiedStations[station] {
+				valid = false
+				break
+			}
+		}
+		if valid {
+			combo = append(combo, comboMember{index: j, route: otherRoute})
+			for _, station := range otherRoute[1 : len(otherRoute)-1] {
+				occupiedStations[station] = true
+			}
+		}
+	}
+	return combo
+}
+
+// comboRoutes returns the routes of a combination
+func comboRoutes(combo []comboMember) [][]string {
+	routes := make([][]string, len(combo))
+	for i, member := range combo {
+		routes[i] = member.route
+	}
+	return routes
 }
 
 // SelectOptimalCombos selects the best combinations of routes
@@ -110,7 +276,7 @@ func SelectOptimalCombos(combos [][][]string) [][][]string {
 	}
 
 	sort.SliceStable(optimalCombos, func(a, b int) bool {
-		return len(optimalCombos[a][0]) < len(optimalCombos[b][0])
+		return routeLess(optimalCombos[a][0], optimalCombos[b][0])
 	})
 
 	return optimalCombos
@@ -118,6 +284,18 @@ func SelectOptimalCombos(combos [][][]string) [][][]string {
 
 // AllocateTrains determines the best routes for the trains to minimize turns
 func AllocateTrains(trainCount int, optimalCombos [][][]string) routePlan {
+	return allocateTrains(trainCount, optimalCombos, 1)
+}
+
+// allocateTrains is AllocateTrains that spreads the combinations over up to workers goroutines
+func allocateTrains(trainCount int, optimalCombos [][][]string, workers int) routePlan {
+	plans := distributeTrains(trainCount, optimalCombos, workers)
+	return plans[bestPlanIndex(plans)]
+}
+
+// distributeTrains spreads the trains over the routes of every combination, always onto the route
+// where the next train arrives soonest, and returns one plan per combination in the same order
+func distributeTrains(trainCount int, optimalCombos [][][]string, workers int) []routePlan {
 	plans := make([]routePlan, len(optimalCombos))
 	for i, combo := range optimalCombos {
 		for _, route := range combo {
@@ -127,7 +305,7 @@ func AllocateTrains(trainCount int, optimalCombos [][][]string) routePlan {
 		plans[i].routes = combo
 	}
 
-	for i := range optimalCombos {
+	parallelFor(workers, len(optimalCombos), func(i int) {
 		trainsLeft := trainCount
 		for trainsLeft > 0 {
 			shortest := plans[i].trainDistribution[0]
@@ -142,25 +320,49 @@ func AllocateTrains(trainCount int, optimalCombos [][][]string) routePlan {
 			trainsLeft--
 		}
 		plans[i].totalTurns = plans[i].trainDistribution[0]
-	}
-
-	minTurns := plans[0].totalTurns
-	bestPlan := plans[0]
-	for _, plan := range plans {
-		if plan.totalTurns < minTurns {
-			minTurns = plan.totalTurns
-			bestPlan = plan
+		// what is left above the route length is the number of trains sent along the route
+		for j, length := range plans[i].lengths {
+			plans[i].trainDistribution[j] -= length
 		}
-		for j, length := range plan.lengths {
-			plan.trainDistribution[j] -= length
+	})
+	return plans
+}
+
+// bestPlanIndex returns the first plan with the fewest turns
+func bestPlanIndex(plans []routePlan) int {
+	best := 0
+	for i, plan := range plans {
+		if plan.totalTurns < plans[best].totalTurns {
+			best = i
 		}
 	}
-
-	return bestPlan
+	return best
 }
 
 // DisplaySchedule prints the train movements per turn
 func DisplaySchedule(plan routePlan, trainCount int) {
+	WriteSchedule(os.Stdout, plan, trainCount)
+}
+
+// WriteSchedule writes the train movements per turn to w, one line per turn
+func WriteSchedule(w io.Writer, plan routePlan, trainCount int) {
+	writeMoves(w, scheduleMoves(plan, trainCount))
+}
+
+// writeMoves writes the moves of every turn to w, one line per turn
+func writeMoves(w io.Writer, schedule [][]string) {
+	for _, moves := range schedule {
+		for _, move := range moves {
+			fmt.Fprintf(w, "%s ", move)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// scheduleMoves returns the moves of every turn written as T<train>-<station>
+func scheduleMoves(plan routePlan, trainCount int) [][]string {
+	// the distribution is counted down while trains depart, work on a copy so the plan can be written again
+	plan.trainDistribution = append([]int{}, plan.trainDistribution...)
 	trains := make([]trainStatus, trainCount)
 	schedule := make([][]trainStatus, plan.totalTurns)
 
@@ -192,12 +394,28 @@ func DisplaySchedule(plan routePlan, trainCount int) {
 		}
 	}
 
-	for _, turn := range schedule {
-		for _, train := range turn {
-			fmt.Printf("T%d-%s ", train.id, train.location)
+	moves := make([][]string, len(schedule))
+	for turn, trains := range schedule {
+		moves[turn] = []string{}
+		for _, train := range trains {
+			moves[turn] = append(moves[turn], fmt.Sprintf("T%d-%s", train.id, formatName(train.location)))
 		}
-		fmt.Println()
 	}
+	return moves
+}
+
+// routeLess orders routes by length and routes of the same length by their station names,
+// so every planning step breaks ties the same way no matter in which order routes were found
+func routeLess(a, b []string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
 }
 
 // Helper function to check if a slice contains an item
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"os"
 	"reflect"
@@ -304,3 +305,150 @@ func TestDisplaySchedule(t *testing.T) {
 		})
 	}
 }
+
+// shippedQuery is a planning request on one of the shipped maps, as listed in main.go
+type shippedQuery struct {
+	file        string
+	source, end string
+	trains      int
+}
+
+var shippedQueries = []shippedQuery{
+	{"network.map", "waterloo", "st_pancras", 3},
+	{"network1.map", "waterloo", "st_pancras", 3},
+	{"network2.map", "waterloo", "st_pancras", 4},
+	{"network3.map", "waterloo", "st_pancras", 2},
+	{"network5.map", "two", "four", 4},
+	{"network6.map", "jungle", "desert", 10},
+	{"network7.map", "small", "large", 9},
+	{"network8.map", "beethoven", "part", 9},
+	{"network10.map", "beginning", "terminus", 20},
+	{"network11.map", "bond_square", "space_port", 4},
+	{"network12.map", "Tallinn-Väike", "Tartu", 4},
+	{"network13.map", "harbour", "airport", 4},
+}
+
+// TestRouteLess tests ordering routes by length and then by station names
+func TestRouteLess(t *testing.T) {
+	tests := []struct {
+		a, b     []string
+		expected bool
+	}{
+		{[]string{"start", "end"}, []string{"start", "A", "end"}, true},
+		{[]string{"start", "B", "end"}, []string{"start", "A", "end"}, false},
+		{[]string{"start", "A", "end"}, []string{"start", "B", "end"}, true},
+		{[]string{"start", "A", "end"}, []string{"start", "A", "end"}, false},
+	}
+	for _, tt := range tests {
+		if result := routeLess(tt.a, tt.b); result != tt.expected {
+			t.Errorf("routeLess(%v, %v) = %v, want %v", tt.a, tt.b, result, tt.expected)
+		}
+	}
+}
+
+// TestPlan_Deterministic loads and plans every shipped map many times, with different numbers
+// of workers, and expects the very same schedule every time
+func TestPlan_Deterministic(t *testing.T) {
+	for _, query := range shippedQueries {
+		t.Run(query.file, func(t *testing.T) {
+			var first string
+			for run := 0; run < 30; run++ {
+				network, err := LoadNetworkMap(query.file)
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				result, err := network.PlanContext(context.Background(), query.source, query.end, query.trains, PlanOptions{ExploreOptions: ExploreOptions{Workers: run % 4}})
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				var out bytes.Buffer
+				WriteSchedule(&out, result.Plan, query.trains)
+				if run == 0 {
+					first = out.String()
+				} else if out.String() != first {
+					t.Fatalf("run %d printed a different schedule\nfirst:\n%s\nnow:\n%s", run, first, out.String())
+				}
+			}
+		})
+	}
+}
+
+// benchStages holds the output of every planner stage for a benchmark case, so each benchmark times only its own stage
+type benchStages struct {
+	network             *RailNetwork
+	source, destination string
+	routes              [][]string
+	validRoutes         [][][]string
+	plan                routePlan
+}
+
+const benchTrains = 10
+
+// prepareBench generates the map of a benchmark case and runs the whole planner on it once
+func prepareBench(b *testing.B, bench benchCase) benchStages {
+	b.Helper()
+	network, err := GenerateNetwork(bench.options)
+	if err != nil {
+		b.Fatal(err)
+	}
+	stages := benchStages{network: network}
+	stages.source, stages.destination = bench.endpoints()
+	if stages.routes, err = network.ExplorePaths(stages.source, stages.destination); err != nil {
+		b.Fatal(err)
+	}
+	stages.validRoutes = ValidateRoutes(stages.routes)
+	stages.plan = AllocateTrains(benchTrains, SelectOptimalCombos(stages.validRoutes))
+	return stages
+}
+
+// BenchmarkExplorePaths times the route search on the generated benchmark maps
+func BenchmarkExplorePaths(b *testing.B) {
+	for _, bench := range benchCases {
+		stages := prepareBench(b, bench)
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				stages.network.ExplorePaths(stages.source, stages.destination)
+			}
+		})
+	}
+}
+
+// BenchmarkValidateRoutes times building the route combinations
+func BenchmarkValidateRoutes(b *testing.B) {
+	for _, bench := range benchCases {
+		stages := prepareBench(b, bench)
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ValidateRoutes(stages.routes)
+			}
+		})
+	}
+}
+
+// BenchmarkAllocateTrains times choosing the combination and spreading the trains over it
+func BenchmarkAllocateTrains(b *testing.B) {
+	for _, bench := range benchCases {
+		stages := prepareBench(b, bench)
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				AllocateTrains(benchTrains, SelectOptimalCombos(stages.validRoutes))
+			}
+		})
+	}
+}
+
+// BenchmarkDisplaySchedule times writing the turn by turn schedule
+func BenchmarkDisplaySchedule(b *testing.B) {
+	for _, bench := range benchCases {
+		stages := prepareBench(b, bench)
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				WriteSchedule(io.Discard, stages.plan, benchTrains)
+			}
+		})
+	}
+}
@@ -0,0 +1,519 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxUploadBytes is the largest map text accepted in a request body
+const maxUploadBytes = 32 << 20
+
+// mapServer answers planning requests over HTTP for the maps registered with it.
+// Networks are never changed after loading, registering or reloading a map replaces it as a whole,
+// so requests only hold the lock while they look a map up
+type mapServer struct {
+	options     LoadOptions
+	planTimeout time.Duration // limit of a single plan, 0 for no limit
+	workers     int
+	logger      *log.Logger // told about reloads when set
+	cache       *PlanCache  // plans kept between requests, nil to plan every request
+
+	mutex    sync.RWMutex
+	maps     map[string]*servedMap
+	failures map[string]*reloadFailure // the last failed reload of a map, until it loads again
+}
+
+// servedMap is a network registered with the server under a name
+type servedMap struct {
+	name    string
+	file    string // the map file it was loaded from, empty for uploaded maps
+	network *RailNetwork
+	loaded  time.Time
+	version int // 1 when first registered, one more every time the map is replaced
+
+	// files are the map file and the files it includes, state tells when they change
+	files []string
+	state string
+}
+
+// reloadFailure is a changed map file that did not load, the previous version is served meanwhile
+type reloadFailure struct {
+	err    string
+	failed time.Time
+	files  []string
+	state  string
+}
+
+// mapStatus is the JSON description of a registered map and its last reload
+type mapStatus struct {
+	Name    string    `json:"name"`
+	File    string    `json:"file,omitempty"`
+	Version int       `json:"version"`
+	Loaded  time.Time `json:"loaded"`
+	// Error is why the changed map file did not load, the version loaded before is served meanwhile
+	Error  string     `json:"error,omitempty"`
+	Failed *time.Time `json:"failed,omitempty"`
+}
+
+// mapInfo is the JSON description of a registered map
+type mapInfo struct {
+	Name        string    `json:"name"`
+	File        string    `json:"file,omitempty"`
+	Stations    int       `json:"stations"`
+	Connections int       `json:"connections"`
+	Version     int       `json:"version"`
+	Loaded      time.Time `json:"loaded"`
+}
+
+// stationInfo is the JSON description of a station of a registered map
+type stationInfo struct {
+	Name        string            `json:"name"`
+	X           *int              `json:"x,omitempty"`
+	Y           *int              `json:"y,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	Connections []string          `json:"connections"`
+}
+
+// validationReport is the JSON answer to a map sent for validation
+type validationReport struct {
+	Valid       bool   `json:"valid"`
+	Error       string `json:"error,omitempty"`
+	Stations    int    `json:"stations,omitempty"`
+	Connections int    `json:"connections,omitempty"`
+}
+
+// errorReport is the JSON body of every failed request
+type errorReport struct {
+	Error       string   `json:"error"`
+	Suggestions []string `json:"suggestions,omitempty"`
+	Diagnosis   string   `json:"diagnosis,omitempty"`
+}
+
+func newMapServer(options LoadOptions) *mapServer {
+	return &mapServer{
+		options:  options,
+		workers:  runtime.NumCPU(),
+		maps:     make(map[string]*servedMap),
+		failures: make(map[string]*reloadFailure),
+	}
+}
+
+// RegisterFile loads a map file and serves it under the name, Watch reloads it when it changes
+func (server *mapServer) RegisterFile(name, filename string) error {
+	network, files, state, err := server.loadWatched(filename, nil)
+	if err != nil {
+		return err
+	}
+	server.store(&servedMap{name: name, file: filename, network: network, loaded: time.Now(), files: files, state: state})
+	return nil
+}
+
+// store serves a map under its name, replacing the map registered before
+func (server *mapServer) store(served *servedMap) {
+	server.mutex.Lock()
+	served.version = 1
+	previous, exists := server.maps[served.name]
+	if exists {
+		served.version = previous.version + 1
+	}
+	server.maps[served.name] = served
+	delete(server.failures, served.name)
+	var unused string
+	if exists {
+		unused = server.unusedHash(previous)
+	}
+	server.mutex.Unlock()
+	server.forget(served.name, unused)
+}
+
+// unusedHash returns the content hash of a map that is no longer served, or "" when there is no cache
+// or another name serves the same network. The lock must be held
+func (server *mapServer) unusedHash(served *servedMap) string {
+	if server.cache == nil {
+		return ""
+	}
+	hash := served.network.ContentHash()
+	for _, other := range server.maps {
+		if other.network.ContentHash() == hash {
+			return ""
+		}
+	}
+	return hash
+}
+
+// forget drops the cached plans of a hash from unusedHash. It removes files, so the lock must not be held
+func (server *mapServer) forget(name, hash string) {
+	if hash == "" {
+		return
+	}
+	if err := server.cache.Forget(hash); err != nil {
+		server.logf("dropping the cached plans of %s failed: %v", name, err)
+	}
+}
+
+// Watch reloads the maps registered from files every time one of the files they were read from
+// changes, checking every interval until ctx is done. A map that fails to load keeps its previous version
+func (server *mapServer) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			server.reloadChanged()
+		}
+	}
+}
+
+// reloadChanged checks every map registered from a file once and reloads the changed ones
+func (server *mapServer) reloadChanged() {
+	server.mutex.RLock()
+	var watched []*servedMap
+	for _, served := range server.maps {
+		if served.file != "" {
+			watched = append(watched, served)
+		}
+	}
+	server.mutex.RUnlock()
+	for _, served := range watched {
+		server.reload(served)
+	}
+}
+
+// reload loads the map again when its files changed since it was loaded or last failed to load
+func (server *mapServer) reload(served *servedMap) {
+	files, state := served.files, served.state
+	server.mutex.RLock()
+	if failure, exists := server.failures[served.name]; exists {
+		files, state = failure.files, failure.state
+	}
+	server.mutex.RUnlock()
+	if fileState(files) == state {
+		return
+	}
+
+	network, files, state, err := server.loadWatched(served.file, files)
+	server.mutex.Lock()
+	if server.maps[served.name] != served {
+		server.mutex.Unlock()
+		return // removed or replaced while it was loading
+	}
+	if err != nil {
+		server.failures[served.name] = &reloadFailure{err: err.Error(), failed: time.Now(), files: files, state: state}
+		server.mutex.Unlock()
+		server.logf("reloading %s failed, still serving version %d: %v", served.name, served.version, err)
+		return
+	}
+	reloaded := &servedMap{name: served.name, file: served.file, network: network, loaded: time.Now(),
+		version: served.version + 1, files: files, state: state}
+	server.maps[served.name] = reloaded
+	delete(server.failures, served.name)
+	unused := server.unusedHash(served)
+	server.mutex.Unlock()
+	server.forget(served.name, unused)
+	server.logf("reloaded %s from %s, version %d", served.name, served.file, reloaded.version)
+}
+
+// loadWatched loads a map file and returns the files it read with their state. When the files are
+// known from the previous load their state is taken before reading, so a change made during the load
+// is noticed by the next check
+func (server *mapServer) loadWatched(filename string, known []string) (*RailNetwork, []string, string, error) {
+	before := fileState(known)
+	network, files, err := loadNetworkMapFiles(filename, server.options)
+	state := before
+	if !slices.Equal(files, known) {
+		state = fileState(files)
+	}
+	if err != nil {
+		return nil, files, state, fmt.Errorf("%s: %v", filename, err)
+	}
+	return network, files, state, nil
+}
+
+// fileState describes the size and modification time of every file, it differs whenever one of them changed
+func fileState(files []string) string {
+	var state strings.Builder
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			fmt.Fprintf(&state, "%s: %v\n", file, err)
+			continue
+		}
+		fmt.Fprintf(&state, "%s: %d %d\n", file, info.Size(), info.ModTime().UnixNano())
+	}
+	return state.String()
+}
+
+func (server *mapServer) logf(format string, args ...interface{}) {
+	if server.logger != nil {
+		server.logger.Printf(format, args...)
+	}
+}
+
+func (server *mapServer) lookup(name string) (*servedMap, bool) {
+	server.mutex.RLock()
+	defer server.mutex.RUnlock()
+	served, exists := server.maps[name]
+	return served, exists
+}
+
+// mapName is the name a map file is served under: its file name without the extension
+func mapName(filename string) string {
+	return strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+}
+
+// Handler returns the HTTP handler of the API:
+//
+//	GET    /maps                  list the registered maps
+//	PUT    /maps/{name}           register the map text in the body under the name
+//	GET    /maps/{name}           describe a map
+//	DELETE /maps/{name}           forget a map
+//	GET    /maps/{name}/stations  list the stations of a map
+//	GET    /maps/{name}/plan      plan ?start=a&end=b&trains=n, answered like the -json option
+//	POST   /validate              check the map text in the body without registering it
+//	GET    /status                the version of every map and why its file last failed to reload
+func (server *mapServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/maps", server.handleMaps)
+	mux.HandleFunc("/maps/", server.handleMap)
+	mux.HandleFunc("/validate", server.handleValidate)
+	mux.HandleFunc("/status", server.handleStatus)
+	return mux
+}
+
+func (server *mapServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	server.mutex.RLock()
+	statuses := make([]mapStatus, 0, len(server.maps))
+	for name, served := range server.maps {
+		status := mapStatus{Name: name, File: served.file, Version: served.version, Loaded: served.loaded}
+		if failure, exists := server.failures[name]; exists {
+			failed := failure.failed
+			status.Error, status.Failed = failure.err, &failed
+		}
+		statuses = append(statuses, status)
+	}
+	server.mutex.RUnlock()
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+func (server *mapServer) handleMaps(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	server.mutex.RLock()
+	infos := make([]mapInfo, 0, len(server.maps))
+	for _, served := range server.maps {
+		infos = append(infos, served.info())
+	}
+	server.mutex.RUnlock()
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	writeJSON(w, http.StatusOK, infos)
+}
+
+func (server *mapServer) handleMap(w http.ResponseWriter, r *http.Request) {
+	name, resource, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/maps/"), "/")
+	if name == "" {
+		writeError(w, http.StatusNotFound, errors.New("map name is missing"))
+		return
+	}
+
+	switch resource {
+	case "":
+		if !allowMethods(w, r, http.MethodGet, http.MethodPut, http.MethodDelete) {
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			server.handleUpload(w, r, name)
+			return
+		case http.MethodDelete:
+			server.mutex.Lock()
+			served, exists := server.maps[name]
+			delete(server.maps, name)
+			delete(server.failures, name)
+			var unused string
+			if exists {
+				unused = server.unusedHash(served)
+			}
+			server.mutex.Unlock()
+			server.forget(name, unused)
+			if !exists {
+				writeError(w, http.StatusNotFound, fmt.Errorf("map %s is not registered", name))
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	case "stations", "plan":
+		if !allowMethods(w, r, http.MethodGet) {
+			return
+		}
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown resource %s", r.URL.Path))
+		return
+	}
+
+	served, exists := server.lookup(name)
+	if !exists {
+		writeError(w, http.StatusNotFound, fmt.Errorf("map %s is not registered", name))
+		return
+	}
+	switch resource {
+	case "":
+		writeJSON(w, http.StatusOK, served.info())
+	case "stations":
+		writeJSON(w, http.StatusOK, served.stations())
+	case "plan":
+		server.handlePlan(w, r, served)
+	}
+}
+
+func (server *mapServer) handleUpload(w http.ResponseWriter, r *http.Request, name string) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxUploadBytes))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+	network, err := LoadNetworkMapData(name, data, server.options)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	served := &servedMap{name: name, network: network, loaded: time.Now()}
+	server.store(served)
+	writeJSON(w, http.StatusCreated, served.info())
+}
+
+func (server *mapServer) handlePlan(w http.ResponseWriter, r *http.Request, served *servedMap) {
+	query := r.URL.Query()
+	source, destination := query.Get("start"), query.Get("end")
+	if source == "" || destination == "" {
+		writeError(w, http.StatusBadRequest, errors.New("start and end parameters are required"))
+		return
+	}
+	trainCount, err := strconv.Atoi(query.Get("trains"))
+	if err != nil || trainCount <= 0 {
+		writeError(w, http.StatusBadRequest, errors.New("number of trains must be a valid positive integer"))
+		return
+	}
+
+	ctx := r.Context()
+	if server.planTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, server.planTimeout)
+		defer cancel()
+	}
+	options := PlanOptions{ExploreOptions: ExploreOptions{Workers: server.workers}}
+	result, cached, err := server.cache.Plan(ctx, served.network, source, destination, trainCount, options)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if cached {
+		w.Header().Set("X-Cache", "hit")
+	} else if server.cache != nil {
+		w.Header().Set("X-Cache", "miss")
+	}
+	writeJSON(w, http.StatusOK, newPlanReport(result, source, destination, trainCount))
+}
+
+func (server *mapServer) handleValidate(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxUploadBytes))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+	network, err := LoadNetworkMapData("map", data, server.options)
+	if err != nil {
+		writeJSON(w, http.StatusOK, validationReport{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, validationReport{Valid: true, Stations: len(network.stations), Connections: len(network.sortedLinks())})
+}
+
+func (served *servedMap) info() mapInfo {
+	return mapInfo{
+		Name:        served.name,
+		File:        served.file,
+		Stations:    len(served.network.stations),
+		Connections: len(served.network.sortedLinks()),
+		Version:     served.version,
+		Loaded:      served.loaded,
+	}
+}
+
+func (served *servedMap) stations() []stationInfo {
+	network := served.network
+	infos := make([]stationInfo, 0, len(network.stations))
+	for _, name := range network.sortedStationNames() {
+		location := network.stations[name]
+		info := stationInfo{Name: name, Attributes: location.attributes, Connections: []string{}}
+		if location.placed {
+			x, y := location.x, location.y
+			info.X, info.Y = &x, &y
+		}
+		for neighbor := range network.links[name] {
+			info.Connections = append(info.Connections, neighbor)
+		}
+		sort.Strings(info.Connections)
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// allowMethods answers 405 Method Not Allowed and returns false when the request uses another method
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", r.Method))
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(value)
+}
+
+// writeError answers with the error message and, for unknown stations and stations no route joins,
+// the suggested names or the diagnosis
+func writeError(w http.ResponseWriter, status int, err error) {
+	report := errorReport{Error: err.Error()}
+	var unknown *UnknownStationError
+	if errors.As(err, &unknown) {
+		report.Suggestions = unknown.Suggestions
+	}
+	var noRoute *NoRouteError
+	if errors.As(err, &noRoute) {
+		report.Diagnosis = noRoute.Diagnosis()
+	}
+	writeJSON(w, status, report)
+}
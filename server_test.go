@@ -0,0 +1,399 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestServer serves network3.map as london and network7.map as grid
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := newMapServer(DefaultLoadOptions)
+	for name, file := range map[string]string{"london": "network3.map", "grid": "network7.map"} {
+		if err := server.RegisterFile(name, file); err != nil {
+			t.Fatalf("Failed to register %s: %v", file, err)
+		}
+	}
+	ts := httptest.NewServer(server.Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// request sends a request and decodes the JSON answer into value, returning the status code
+func request(t *testing.T, method, target, body string, value interface{}) int {
+	t.Helper()
+	req, err := http.NewRequest(method, target, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != nil {
+		if err := json.Unmarshal(data, value); err != nil {
+			t.Fatalf("Failed to decode %s: %v", data, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// TestServer_Plan tests that a plan is answered like the -json option prints it
+func TestServer_Plan(t *testing.T) {
+	ts := newTestServer(t)
+
+	var report planReport
+	status := request(t, http.MethodGet, ts.URL+"/maps/london/plan?start=waterloo&end=st_pancras&trains=2", "", &report)
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	network, err := LoadNetworkMap("network3.map")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := network.Plan("waterloo", "st_pancras", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := newPlanReport(PlanResult{Plan: plan, LowerBound: 2}, "waterloo", "st_pancras", 2)
+	if !reflect.DeepEqual(report, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, report)
+	}
+}
+
+// TestServer_PlanErrors tests the status codes and messages of plans that cannot be made
+func TestServer_PlanErrors(t *testing.T) {
+	ts := newTestServer(t)
+	tests := []struct {
+		target   string
+		status   int
+		expected errorReport
+	}{
+		{"/maps/london/plan?start=waterloo&end=st_pancras", http.StatusBadRequest,
+			errorReport{Error: "number of trains must be a valid positive integer"}},
+		{"/maps/london/plan?end=st_pancras&trains=1", http.StatusBadRequest,
+			errorReport{Error: "start and end parameters are required"}},
+		{"/maps/london/plan?start=waterlo&end=st_pancras&trains=1", http.StatusBadRequest,
+			errorReport{Error: "source station waterlo does not exist", Suggestions: []string{"waterloo"}}},
+		{"/maps/paris/plan?start=a&end=b&trains=1", http.StatusNotFound,
+			errorReport{Error: "map paris is not registered"}},
+		{"/maps/london/routes", http.StatusNotFound,
+			errorReport{Error: "unknown resource /maps/london/routes"}},
+	}
+	for _, test := range tests {
+		var report errorReport
+		if status := request(t, http.MethodGet, ts.URL+test.target, "", &report); status != test.status || !reflect.DeepEqual(report, test.expected) {
+			t.Errorf("Expected %d %+v for %s, got %d %+v", test.status, test.expected, test.target, status, report)
+		}
+	}
+}
+
+// TestServer_Maps tests registering, listing, describing and removing maps
+func TestServer_Maps(t *testing.T) {
+	ts := newTestServer(t)
+
+	var info mapInfo
+	upload := "stations:\na,0,0\nb,1,0\nc,2,0\nconnections:\na-b\nb-c\n"
+	if status := request(t, http.MethodPut, ts.URL+"/maps/line", upload, &info); status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", status)
+	}
+	if info.Name != "line" || info.File != "" || info.Stations != 3 || info.Connections != 2 {
+		t.Fatalf("Unexpected map info %+v", info)
+	}
+
+	var infos []mapInfo
+	request(t, http.MethodGet, ts.URL+"/maps", "", &infos)
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"grid", "line", "london"}) {
+		t.Fatalf("Expected grid, line and london, got %v", names)
+	}
+
+	if status := request(t, http.MethodGet, ts.URL+"/maps/london", "", &info); status != http.StatusOK || info.File != "network3.map" || info.Stations != 4 {
+		t.Fatalf("Unexpected answer %d %+v", status, info)
+	}
+	if status := request(t, http.MethodDelete, ts.URL+"/maps/line", "", nil); status != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", status)
+	}
+	if status := request(t, http.MethodDelete, ts.URL+"/maps/line", "", nil); status != http.StatusNotFound {
+		t.Fatalf("Expected status 404 for a removed map, got %d", status)
+	}
+
+	var report errorReport
+	status := request(t, http.MethodPut, ts.URL+"/maps/broken", "stations:\na,0,0\nconnections:\na-b\n", &report)
+	if status != http.StatusUnprocessableEntity || report.Error != "station b does not exist" {
+		t.Fatalf("Expected 422 with 'station b does not exist', got %d %+v", status, report)
+	}
+	if status := request(t, http.MethodPost, ts.URL+"/maps/london", "", &report); status != http.StatusMethodNotAllowed {
+		t.Fatalf("Expected status 405, got %d", status)
+	}
+}
+
+// TestServer_Stations tests the station list with coordinates, attributes and connections
+func TestServer_Stations(t *testing.T) {
+	ts := newTestServer(t)
+	upload := "stations:\ndepot,0,0 type=depot\n\"Tallinn-Väike\",1,0\nconnections:\ndepot-\"Tallinn-Väike\"\n"
+	request(t, http.MethodPut, ts.URL+"/maps/small", upload, nil)
+
+	var stations []stationInfo
+	if status := request(t, http.MethodGet, ts.URL+"/maps/small/stations", "", &stations); status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	zero, one := 0, 1
+	expected := []stationInfo{
+		{Name: "Tallinn-Väike", X: &one, Y: &zero, Connections: []string{"depot"}},
+		{Name: "depot", X: &zero, Y: &zero, Attributes: map[string]string{"type": "depot"}, Connections: []string{"Tallinn-Väike"}},
+	}
+	if !reflect.DeepEqual(stations, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, stations)
+	}
+}
+
+// TestServer_Validate tests that maps are checked without being registered
+func TestServer_Validate(t *testing.T) {
+	ts := newTestServer(t)
+	data, err := os.ReadFile("network7.map")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		body     string
+		expected validationReport
+	}{
+		{string(data), validationReport{Valid: true, Stations: 27, Connections: 35}},
+		{"", validationReport{Error: "file is empty"}},
+		{"include \"network3.map\"\n", validationReport{Error: "map:1: include \"network3.map\" is only allowed in map files"}},
+	}
+	for _, test := range tests {
+		var report validationReport
+		if status := request(t, http.MethodPost, ts.URL+"/validate", test.body, &report); status != http.StatusOK || report != test.expected {
+			t.Errorf("Expected %+v, got %d %+v", test.expected, status, report)
+		}
+	}
+	var infos []mapInfo
+	if request(t, http.MethodGet, ts.URL+"/maps", "", &infos); len(infos) != 2 {
+		t.Fatalf("Expected validation to register nothing, got %d maps", len(infos))
+	}
+}
+
+// TestServer_Concurrent tests plans running side by side with maps being replaced, run it with -race
+func TestServer_Concurrent(t *testing.T) {
+	ts := newTestServer(t)
+	data, err := os.ReadFile("network7.map")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func(trains int) {
+			defer wg.Done()
+			query := url.Values{"start": {"small"}, "end": {"large"}, "trains": {fmt.Sprint(trains)}}
+			resp, err := http.Get(ts.URL + "/maps/grid/plan?" + query.Encode())
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer resp.Body.Close()
+			var report planReport
+			if err := json.NewDecoder(resp.Body).Decode(&report); err != nil || report.Trains != trains || len(report.Schedule) != report.Turns {
+				errs <- fmt.Errorf("unexpected answer for %d trains: %+v, %v", trains, report, err)
+			}
+		}(i + 1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest(http.MethodPut, ts.URL+"/maps/grid", strings.NewReader(string(data)))
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				errs <- err
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// writeWatched writes a map file and moves its modification time forward, so every write is seen as a change
+func writeWatched(t *testing.T, path, contents string, generation int) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	modified := time.Now().Add(time.Duration(generation) * time.Second)
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// status returns the status of the named map
+func status(t *testing.T, server *mapServer, name string) mapStatus {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/status", nil))
+	var statuses []mapStatus
+	if err := json.Unmarshal(recorder.Body.Bytes(), &statuses); err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if status.Name == name {
+			return status
+		}
+	}
+	t.Fatalf("No status for %s in %+v", name, statuses)
+	return mapStatus{}
+}
+
+// TestServer_Reload tests that a changed map is swapped in, and a broken one keeps the previous version
+func TestServer_Reload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "line.map")
+	writeWatched(t, path, "stations:\na,0,0\nb,1,0\nconnections:\na-b\n", 0)
+	server := newMapServer(DefaultLoadOptions)
+	if err := server.RegisterFile("line", path); err != nil {
+		t.Fatal(err)
+	}
+
+	server.reloadChanged()
+	if current := status(t, server, "line"); current.Version != 1 || current.Error != "" {
+		t.Fatalf("Expected an unchanged map to stay at version 1, got %+v", current)
+	}
+
+	writeWatched(t, path, "stations:\na,0,0\nb,1,0\nc,2,0\nconnections:\na-b\nb-c\n", 1)
+	server.reloadChanged()
+	served, _ := server.lookup("line")
+	if served.version != 2 || len(served.network.stations) != 3 {
+		t.Fatalf("Expected version 2 with 3 stations, got version %d with %d", served.version, len(served.network.stations))
+	}
+
+	writeWatched(t, path, "stations:\na,0,0\nconnections:\na-x\n", 2)
+	server.reloadChanged()
+	current := status(t, server, "line")
+	if current.Version != 2 || current.Error != path+": station x does not exist" || current.Failed == nil {
+		t.Fatalf("Expected version 2 to be kept with the load error, got %+v", current)
+	}
+	if served, _ := server.lookup("line"); len(served.network.stations) != 3 {
+		t.Fatalf("Expected the previous network to be served, got %d stations", len(served.network.stations))
+	}
+	// a failed version is not loaded again until it changes
+	server.reloadChanged()
+	if again := status(t, server, "line"); !again.Failed.Equal(*current.Failed) {
+		t.Fatalf("Expected no new attempt, failed at %v and %v", current.Failed, again.Failed)
+	}
+
+	writeWatched(t, path, "stations:\na,0,0\nx,0,1\nconnections:\na-x\n", 3)
+	server.reloadChanged()
+	if current := status(t, server, "line"); current.Version != 3 || current.Error != "" || current.Failed != nil {
+		t.Fatalf("Expected version 3 without an error, got %+v", current)
+	}
+}
+
+// TestServer_ReloadInclude tests that a change in an included file reloads the map including it
+func TestServer_ReloadInclude(t *testing.T) {
+	dir := writeMapFiles(t, map[string]string{
+		"main.map":  "include \"north.map\"\nstations:\nhub,0,0\nconnections:\nhub-n1\n",
+		"north.map": "stations:\nn1,0,1\nconnections:\n",
+	})
+	server := newMapServer(DefaultLoadOptions)
+	if err := server.RegisterFile("main", filepath.Join(dir, "main.map")); err != nil {
+		t.Fatal(err)
+	}
+	writeWatched(t, filepath.Join(dir, "north.map"), "stations:\nn1,0,1\nn2,0,2\nconnections:\nn1-n2\n", 1)
+	server.reloadChanged()
+	if served, _ := server.lookup("main"); served.version != 2 || len(served.network.stations) != 3 {
+		t.Fatalf("Expected version 2 with 3 stations, got version %d with %d", served.version, len(served.network.stations))
+	}
+}
+
+// TestServer_Watch tests the watch loop and that uploaded maps are not watched
+func TestServer_Watch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "line.map")
+	writeWatched(t, path, "stations:\na,0,0\nb,1,0\nconnections:\na-b\n", 0)
+	server := newMapServer(DefaultLoadOptions)
+	if err := server.RegisterFile("line", path); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+	request(t, http.MethodPut, ts.URL+"/maps/uploaded", "stations:\na,0,0\nb,1,0\nconnections:\na-b\n", nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.Watch(ctx, 10*time.Millisecond)
+	writeWatched(t, path, "stations:\na,0,0\nb,1,0\nc,2,0\nconnections:\na-b\nb-c\n", 1)
+
+	var report planReport
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if request(t, http.MethodGet, ts.URL+"/maps/line/plan?start=a&end=c&trains=1", "", &report) == http.StatusOK {
+			break
+		}
+	}
+	if report.Turns != 2 {
+		t.Fatalf("Expected the reloaded map to be planned on, got %+v", report)
+	}
+	if uploaded := status(t, server, "uploaded"); uploaded.Version != 1 || uploaded.File != "" {
+		t.Fatalf("Expected the uploaded map to stay at version 1, got %+v", uploaded)
+	}
+}
+
+// TestServer_Cache tests that a repeated plan comes from the cache and a replaced map is planned again
+func TestServer_Cache(t *testing.T) {
+	server := newMapServer(DefaultLoadOptions)
+	server.cache, _ = NewPlanCache("", 10)
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+	line := "stations:\na,0,0\nb,1,0\nc,2,0\nconnections:\na-b\nb-c\n"
+	request(t, http.MethodPut, ts.URL+"/maps/line", line, nil)
+
+	cacheHeader := func() string {
+		resp, err := http.Get(ts.URL + "/maps/line/plan?start=a&end=c&trains=2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.Header.Get("X-Cache")
+	}
+	if first, second := cacheHeader(), cacheHeader(); first != "miss" || second != "hit" {
+		t.Fatalf("Expected a miss and a hit, got %s and %s", first, second)
+	}
+
+	request(t, http.MethodPut, ts.URL+"/maps/line", line+"a-c\n", nil)
+	if server.cache.Len() != 0 {
+		t.Fatalf("Expected the plans of the replaced map to be dropped, got %d", server.cache.Len())
+	}
+	if header := cacheHeader(); header != "miss" {
+		t.Fatalf("Expected the replaced map to be planned again, got %s", header)
+	}
+	// the same map under another name shares its plans
+	request(t, http.MethodPut, ts.URL+"/maps/copy", line+"a-c\n", nil)
+	request(t, http.MethodDelete, ts.URL+"/maps/copy", "", nil)
+	if header := cacheHeader(); header != "hit" {
+		t.Fatalf("Expected the plan to stay cached, got %s", header)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"math"
+)
+
+// ShortestPath returns a route from source to destination with the fewest tracks.
+// It uses A* with the straight line distance to the destination, divided by the longest track,
+// as the estimate of the tracks still needed. When some station has no coordinates the estimate
+// would not be a lower bound and the search falls back to Dijkstra's algorithm
+func (network *RailNetwork) ShortestPath(source, destination string) ([]string, error) {
+	route, err := network.shortestRoute(source, destination, false)
+	return route.Stations, err
+}
+
+// ShortestDistancePath returns the route from source to destination with the shortest total
+// straight line distance between consecutive stations, found with A* in the same way as ShortestPath
+func (network *RailNetwork) ShortestDistancePath(source, destination string) (WeightedRoute, error) {
+	return network.shortestRoute(source, destination, true)
+}
+
+func (network *RailNetwork) shortestRoute(source, destination string, byDistance bool) (WeightedRoute, error) {
+	if err := network.checkEndpoints(source, destination); err != nil {
+		return WeightedRoute{}, err
+	}
+	if err := network.checkConnected(source, destination); err != nil {
+		return WeightedRoute{}, err
+	}
+
+	graph := network.Compact()
+	var search *pathSearch
+	if byDistance {
+		search = newPathSearch(graph, DistanceWeight(network))
+	} else {
+		search = newPathSearch(graph, nil)
+	}
+	target := graph.ids[destination]
+
+	path, cost, found := search.shortestGuided(graph.ids[source], target, nil, nil, network.distanceEstimate(graph, target, byDistance))
+	if !found {
+		return WeightedRoute{}, errors.New("no routes found from start to end")
+	}
+	return WeightedRoute{Stations: graph.route(path), Cost: cost}, nil
+}
+
+// distanceEstimate returns the A* estimate of the remaining cost to target, or nil when the
+// coordinates cannot give a lower bound because some station was never placed
+func (network *RailNetwork) distanceEstimate(graph *compactGraph, target int32, byDistance bool) func(id int32) float64 {
+	for _, location := range network.stations {
+		if !location.placed {
+			return nil
+		}
+	}
+	xs, ys := make([]float64, graph.stationCount()), make([]float64, graph.stationCount())
+	for id, name := range graph.names {
+		xs[id], ys[id] = float64(network.stations[name].x), float64(network.stations[name].y)
+	}
+	distance := func(a, b int32) float64 {
+		return math.Hypot(xs[a]-xs[b], ys[a]-ys[b])
+	}
+	if byDistance {
+		return func(id int32) float64 { return distance(id, target) }
+	}
+
+	// no track is longer than the longest one, so covering the remaining distance takes at least this many tracks
+	longest := 0.0
+	for id := range graph.names {
+		for _, neighbor := range graph.neighbors(int32(id)) {
+			longest = math.Max(longest, distance(int32(id), neighbor))
+		}
+	}
+	if longest == 0 {
+		return nil
+	}
+	return func(id int32) float64 { return math.Ceil(distance(id, target)/longest - 1e-9) }
+}
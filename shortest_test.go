@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+// gridNetwork returns a width by height grid of stations named by their coordinates,
+// every station connected to its neighbors to the right and below
+func gridNetwork(width, height int) *RailNetwork {
+	network := NewRailNetwork()
+	name := func(x, y int) string { return fmt.Sprintf("%d_%d", x, y) }
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			network.AddLocation(name(x, y))
+			network.SetCoordinates(name(x, y), x*10, y*10)
+		}
+	}
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			if x+1 < width {
+				network.AddLink(name(x, y), name(x+1, y))
+			}
+			if y+1 < height {
+				network.AddLink(name(x, y), name(x, y+1))
+			}
+		}
+	}
+	return network
+}
+
+// TestShortestPath_MatchesKShortestPaths tests that A* finds routes as short as the first route of Yen's algorithm
+func TestShortestPath_MatchesKShortestPaths(t *testing.T) {
+	for _, query := range shippedQueries {
+		network, err := LoadNetworkMap(query.file)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		route, err := network.ShortestPath(query.source, query.end)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", query.file, err)
+		}
+		expected, err := network.KShortestPaths(query.source, query.end, 1, PathOptions{})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", query.file, err)
+		}
+		if len(route)-1 != int(expected[0].Cost) {
+			t.Fatalf("%s: expected %v tracks, got %v", query.file, expected[0].Cost, route)
+		}
+		for i := 1; i < len(route); i++ {
+			if !network.links[route[i-1]][route[i]] {
+				t.Fatalf("%s: %s and %s are not connected in %v", query.file, route[i-1], route[i], route)
+			}
+		}
+
+		weighted, err := network.ShortestDistancePath(query.source, query.end)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", query.file, err)
+		}
+		expected, err = network.KShortestPaths(query.source, query.end, 1, PathOptions{Weight: DistanceWeight(network)})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", query.file, err)
+		}
+		if math.Abs(weighted.Cost-expected[0].Cost) > 1e-9 {
+			t.Fatalf("%s: expected distance %v, got %v for %v", query.file, expected[0].Cost, weighted.Cost, weighted.Stations)
+		}
+	}
+}
+
+// TestShortestPath_WithoutCoordinates tests the Dijkstra fallback for stations that were never placed
+func TestShortestPath_WithoutCoordinates(t *testing.T) {
+	network := NewRailNetwork()
+	for _, name := range []string{"a", "b", "c", "d"} {
+		network.AddLocation(name)
+	}
+	network.SetCoordinates("a", 0, 0)
+	network.AddLink("a", "b")
+	network.AddLink("b", "c")
+	network.AddLink("c", "d")
+	network.AddLink("a", "d")
+
+	route, err := network.ShortestPath("a", "c")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(route) != 3 || route[0] != "a" || route[2] != "c" {
+		t.Fatalf("Expected a route of 2 tracks from a to c, got %v", route)
+	}
+}
+
+// TestShortestPath_Errors tests the errors for invalid stations and unreachable destinations
+func TestShortestPath_Errors(t *testing.T) {
+	network := gridNetwork(3, 3)
+	network.AddLocation("island")
+	network.SetCoordinates("island", 100, 100)
+
+	tests := []struct {
+		source, destination, expected string
+	}{
+		{"0_0", "0_0", "source and destination stations are the same"},
+		{"missing", "0_0", "source station missing does not exist"},
+		{"0_0", "missing", "destination station missing does not exist"},
+		{"0_0", "island", "no routes found from start to end"},
+	}
+	for _, test := range tests {
+		_, err := network.ShortestPath(test.source, test.destination)
+		if err == nil || err.Error() != test.expected {
+			t.Fatalf("Expected '%s' error, got: %v", test.expected, err)
+		}
+	}
+}
+
+// BenchmarkShortestPath times A* between opposite corners of large grids
+func BenchmarkShortestPath(b *testing.B) {
+	for _, size := range []int{5, 100, 500} {
+		network := gridNetwork(size, size)
+		network.Compact()
+		end := fmt.Sprintf("%d_%d", size-1, size-1)
+		b.Run(fmt.Sprintf("grid%dx%d", size, size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := network.ShortestPath("0_0", end); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkExplorePaths_Grid times the exhaustive route search on the grids it can still finish,
+// the number of routes between opposite corners grows too fast to go further
+func BenchmarkExplorePaths_Grid(b *testing.B) {
+	for _, size := range []int{4, 5} {
+		network := gridNetwork(size, size)
+		network.Compact()
+		end := fmt.Sprintf("%d_%d", size-1, size-1)
+		b.Run(fmt.Sprintf("grid%dx%d", size, size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := network.ExplorePaths("0_0", end); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
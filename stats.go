@@ -0,0 +1,199 @@
+package main
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// NetworkStats describes the shape of a network
+type NetworkStats struct {
+	Stations    int
+	Connections int
+	// Degrees maps a number of connections to how many stations have that many
+	Degrees map[int]int
+	// Components are the groups of stations reachable from each other, largest first
+	Components [][]string
+	// Diameter is the most tracks on the shortest way between any two connected stations
+	Diameter int
+	// DiameterEstimated is set on networks of more than exactDiameterStations stations, where Diameter
+	// is a lower bound found by two breadth-first searches per component
+	DiameterEstimated bool
+	// ArticulationPoints are the stations whose closure splits their component, sorted by name
+	ArticulationPoints []string
+	// Bridges are the tracks whose closure splits their component, sorted by station pair
+	Bridges [][2]string
+}
+
+// Stats computes the statistics of the network
+func (network *RailNetwork) Stats() NetworkStats {
+	graph := network.Compact()
+	stats := NetworkStats{
+		Stations:    graph.stationCount(),
+		Connections: len(graph.adjacency) / 2,
+		Degrees:     make(map[int]int),
+		Components:  network.ConnectedComponents(),
+	}
+	stats.Diameter, stats.DiameterEstimated = graph.diameter()
+	for id := range graph.names {
+		stats.Degrees[len(graph.neighbors(int32(id)))]++
+	}
+
+	points, bridges := graph.cutElements()
+	for _, id := range points {
+		stats.ArticulationPoints = append(stats.ArticulationPoints, graph.names[id])
+	}
+	sort.Strings(stats.ArticulationPoints)
+	for _, bridge := range bridges {
+		stats.Bridges = append(stats.Bridges, linkKey(graph.names[bridge[0]], graph.names[bridge[1]]))
+	}
+	sort.Slice(stats.Bridges, func(i, j int) bool {
+		if stats.Bridges[i][0] != stats.Bridges[j][0] {
+			return stats.Bridges[i][0] < stats.Bridges[j][0]
+		}
+		return stats.Bridges[i][1] < stats.Bridges[j][1]
+	})
+	return stats
+}
+
+// MaxDisjointRoutes returns how many routes from source to destination can run at the same time
+// without sharing an intermediate station
+func (network *RailNetwork) MaxDisjointRoutes(source, destination string) (int, error) {
+	costs, err := network.DisjointRouteCosts(source, destination, 0)
+	return len(costs), err
+}
+
+// exactDiameterStations is the largest network whose diameter is computed exactly, a search from
+// every station takes quadratic time and larger networks only get an estimate
+const exactDiameterStations = 5000
+
+// diameter returns the longest distance between two connected stations and whether it is an estimate.
+// Up to exactDiameterStations it runs a breadth-first search from every station on all CPUs
+func (graph *compactGraph) diameter() (int, bool) {
+	if graph.stationCount() > exactDiameterStations {
+		return graph.diameterEstimate(), true
+	}
+	var mutex sync.Mutex
+	longest := 0
+	parallelFor(runtime.NumCPU(), graph.stationCount(), func(i int) {
+		farthest := int32(0)
+		for _, distance := range graph.distancesTo(int32(i)) {
+			farthest = max(farthest, distance)
+		}
+		mutex.Lock()
+		longest = max(longest, int(farthest))
+		mutex.Unlock()
+	})
+	return longest, false
+}
+
+// diameterEstimate is the double sweep lower bound of the diameter: in every component the farthest
+// station from any station is searched from again. It is exact on lines and other trees
+func (graph *compactGraph) diameterEstimate() int {
+	distances := make([]int32, graph.stationCount())
+	for i := range distances {
+		distances[i] = -1
+	}
+	reached := make([]bool, graph.stationCount())
+	longest := int32(0)
+	for id := int32(0); id < int32(graph.stationCount()); id++ {
+		if reached[id] {
+			continue
+		}
+		far, _ := graph.farthest(id, distances, reached)
+		_, distance := graph.farthest(far, distances, reached)
+		longest = max(longest, distance)
+	}
+	return int(longest)
+}
+
+// farthest searches breadth-first from start and returns the last station found and its distance,
+// marking the stations it reaches. distances must be -1 everywhere and is left that way, so that
+// searching many small components does not allocate for the whole network every time
+func (graph *compactGraph) farthest(start int32, distances []int32, reached []bool) (int32, int32) {
+	distances[start] = 0
+	queue := []int32{start}
+	for i := 0; i < len(queue); i++ {
+		reached[queue[i]] = true
+		for _, neighbor := range graph.neighbors(queue[i]) {
+			if distances[neighbor] < 0 {
+				distances[neighbor] = distances[queue[i]] + 1
+				queue = append(queue, neighbor)
+			}
+		}
+	}
+	last := queue[len(queue)-1]
+	distance := distances[last]
+	for _, id := range queue {
+		distances[id] = -1
+	}
+	return last, distance
+}
+
+// cutElements finds articulation points and bridges with Tarjan's depth-first search,
+// kept iterative so long chains of stations do not overflow the stack
+func (graph *compactGraph) cutElements() ([]int32, [][2]int32) {
+	n := graph.stationCount()
+	order := make([]int32, n) // discovery time starting at 1, 0 while unvisited
+	low := make([]int32, n)
+	var points []int32
+	var bridges [][2]int32
+	isPoint := make([]bool, n)
+
+	// frame is a station on the search path and the index of the next neighbor to look at
+	type frame struct {
+		id, parent int32
+		next       int
+	}
+	clock := int32(0)
+	for root := int32(0); root < int32(n); root++ {
+		if order[root] != 0 {
+			continue
+		}
+		clock++
+		order[root], low[root] = clock, clock
+		children := 0
+		stack := []frame{{id: root, parent: -1}}
+		for len(stack) > 0 {
+			top := &stack[len(stack)-1]
+			neighbors := graph.neighbors(top.id)
+			if top.next < len(neighbors) {
+				neighbor := neighbors[top.next]
+				top.next++
+				if neighbor == top.parent {
+					continue
+				}
+				if order[neighbor] != 0 {
+					low[top.id] = min(low[top.id], order[neighbor])
+					continue
+				}
+				clock++
+				order[neighbor], low[neighbor] = clock, clock
+				if top.id == root {
+					children++
+				}
+				stack = append(stack, frame{id: neighbor, parent: top.id})
+				continue
+			}
+
+			// every neighbor is done, hand the low value back to the parent
+			stack = stack[:len(stack)-1]
+			if top.parent < 0 {
+				continue
+			}
+			parent := top.parent
+			low[parent] = min(low[parent], low[top.id])
+			if low[top.id] > order[parent] {
+				bridges = append(bridges, [2]int32{parent, top.id})
+			}
+			if parent != root && low[top.id] >= order[parent] && !isPoint[parent] {
+				isPoint[parent] = true
+				points = append(points, parent)
+			}
+		}
+		if children > 1 {
+			points = append(points, root)
+		}
+	}
+	return points, bridges
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// TestStats tests the statistics of two triangles joined by a track, with a tail and an isolated station
+func TestStats(t *testing.T) {
+	network := NewRailNetwork()
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "lonely"} {
+		network.AddLocation(name)
+	}
+	for _, link := range [][2]string{{"a", "b"}, {"b", "c"}, {"c", "a"}, {"c", "d"}, {"d", "e"}, {"e", "f"}, {"f", "d"}, {"f", "g"}} {
+		network.AddLink(link[0], link[1])
+	}
+
+	stats := network.Stats()
+	if stats.Stations != 8 || stats.Connections != 8 {
+		t.Fatalf("Expected 8 stations and 8 connections, got %d and %d", stats.Stations, stats.Connections)
+	}
+	if !reflect.DeepEqual(stats.Degrees, map[int]int{0: 1, 1: 1, 2: 3, 3: 3}) {
+		t.Fatalf("Unexpected degree distribution %v", stats.Degrees)
+	}
+	if len(stats.Components) != 2 || len(stats.Components[0]) != 7 {
+		t.Fatalf("Expected components of 7 and 1 stations, got %v", stats.Components)
+	}
+	// a-c-d-f-g
+	if stats.Diameter != 4 {
+		t.Fatalf("Expected diameter 4, got %d", stats.Diameter)
+	}
+	if !reflect.DeepEqual(stats.ArticulationPoints, []string{"c", "d", "f"}) {
+		t.Fatalf("Expected articulation points c, d and f, got %v", stats.ArticulationPoints)
+	}
+	if !reflect.DeepEqual(stats.Bridges, [][2]string{{"c", "d"}, {"f", "g"}}) {
+		t.Fatalf("Expected bridges c-d and f-g, got %v", stats.Bridges)
+	}
+}
+
+// TestStats_RandomNetworks compares articulation points and bridges with closing every station and track in turn
+func TestStats_RandomNetworks(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		n := 2 + rng.Intn(10)
+		network := randomConnectedNetwork(rng, n, rng.Intn(n))
+		stats := network.Stats()
+
+		var points []string
+		for _, name := range network.sortedStationNames() {
+			if len(network.Without(name).ConnectedComponents()) > 1 {
+				points = append(points, name)
+			}
+		}
+		var bridges [][2]string
+		for _, link := range network.sortedLinks() {
+			without := network.Without()
+			delete(without.links[link[0]], link[1])
+			delete(without.links[link[1]], link[0])
+			if len(without.ConnectedComponents()) > 1 {
+				bridges = append(bridges, link)
+			}
+		}
+		if !reflect.DeepEqual(stats.ArticulationPoints, points) || !reflect.DeepEqual(stats.Bridges, bridges) {
+			t.Fatalf("case %d: expected %v and %v, got %v and %v", i, points, bridges, stats.ArticulationPoints, stats.Bridges)
+		}
+		if estimate := network.Compact().diameterEstimate(); estimate > stats.Diameter || 2*estimate < stats.Diameter {
+			t.Fatalf("case %d: expected an estimate between half the diameter %d and the diameter, got %d", i, stats.Diameter, estimate)
+		}
+	}
+}
+
+// TestStats_LongLine tests that a long line of stations does not overflow the stack
+func TestStats_LongLine(t *testing.T) {
+	network := NewRailNetwork()
+	for i := 0; i < 50000; i++ {
+		network.AddLocation(fmt.Sprintf("s%05d", i))
+		if i > 0 {
+			network.AddLink(fmt.Sprintf("s%05d", i-1), fmt.Sprintf("s%05d", i))
+		}
+	}
+	points, bridges := network.Compact().cutElements()
+	if len(points) != 49998 || len(bridges) != 49999 {
+		t.Fatalf("Expected 49998 articulation points and 49999 bridges, got %d and %d", len(points), len(bridges))
+	}
+
+	// far too many stations to search from each one, the estimate is exact on a line
+	if stats := network.Stats(); stats.Diameter != 49999 || !stats.DiameterEstimated {
+		t.Fatalf("Expected an estimated diameter of 49999, got %d (estimated %v)", stats.Diameter, stats.DiameterEstimated)
+	}
+}
+
+// TestMaxDisjointRoutes tests the number of routes without shared stations on the shipped maps
+func TestMaxDisjointRoutes(t *testing.T) {
+	tests := []struct {
+		file, source, end string
+		expected          int
+	}{
+		{"network3.map", "waterloo", "st_pancras", 2},
+		{"network7.map", "small", "large", 4},
+	}
+	for _, test := range tests {
+		network, err := LoadNetworkMap(test.file)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		routes, err := network.MaxDisjointRoutes(test.source, test.end)
+		if err != nil || routes != test.expected {
+			t.Fatalf("%s: expected %d routes, got %d (%v)", test.file, test.expected, routes, err)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+//go:build linux
+
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// makeRaw switches the terminal on fd to raw mode for the line editor and returns a function that
+// restores the previous mode. An error means fd is not a terminal
+func makeRaw(fd int) (func(), error) {
+	var previous syscall.Termios
+	if err := termios(fd, syscall.TCGETS, &previous); err != nil {
+		return nil, err
+	}
+	raw := previous
+	raw.Iflag &^= syscall.BRKINT | syscall.ICRNL | syscall.INPCK | syscall.ISTRIP | syscall.IXON
+	raw.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.IEXTEN | syscall.ISIG
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := termios(fd, syscall.TCSETS, &raw); err != nil {
+		return nil, err
+	}
+	return func() { termios(fd, syscall.TCSETS, &previous) }, nil
+}
+
+func termios(fd int, request uintptr, state *syscall.Termios) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, uintptr(unsafe.Pointer(state))); errno != 0 {
+		return errno
+	}
+	return nil
+}
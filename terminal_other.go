@@ -0,0 +1,10 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// makeRaw is only implemented for Linux, elsewhere the shell reads plain lines
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("line editing is not supported on this system")
+}